@@ -0,0 +1,63 @@
+// mockldap 是用于开发和端到端测试 LDAP 登录的本地目录服务器。
+// 服务账户为 cn=admin,dc=example,dc=com / admin，用户位于 ou=people,dc=example,dc=com。
+//
+//	go run ./cmd/mockldap -addr :3890 -user alice:secret:alice@example.com:admins -user bob:secret
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"backend/internal/auth/ldaptest"
+)
+
+// userFlags 可重复的 -user 参数，格式为 uid:密码[:邮箱[:组1|组2]]
+type userFlags []ldaptest.User
+
+func (u *userFlags) String() string {
+	return ""
+}
+
+func (u *userFlags) Set(value string) error {
+	parts := strings.SplitN(value, ":", 4)
+	if len(parts) < 2 || parts[0] == "" {
+		return flag.ErrHelp
+	}
+	user := ldaptest.User{UID: parts[0], Password: parts[1]}
+	if len(parts) > 2 {
+		user.Mail = parts[2]
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		user.Groups = strings.Split(parts[3], "|")
+	}
+	*u = append(*u, user)
+	return nil
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:3890", "监听地址")
+	var users userFlags
+	flag.Var(&users, "user", "目录用户，格式为 uid:密码[:邮箱[:组1|组2]]，可重复")
+	flag.Parse()
+
+	if len(users) == 0 {
+		users = userFlags{{UID: "alice", Password: "secret", Mail: "alice@example.com", Groups: []string{"admins"}}}
+	}
+
+	server, err := ldaptest.Start(*addr, users...)
+	if err != nil {
+		log.Fatalf("Failed to start LDAP server: %v", err)
+	}
+	for _, u := range users {
+		log.Printf("User %s (groups: %s)", u.DN(), strings.Join(u.Groups, ","))
+	}
+	log.Printf("Mock LDAP server listening on %s, base DN %s", *addr, ldaptest.BaseDN)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	server.Stop()
+}
@@ -0,0 +1,46 @@
+// mockoidc 是用于开发和端到端测试单点登录的本地 OIDC 身份提供方。
+// 它自动批准所有授权请求，以命令行参数指定的用户身份签发令牌，并校验 PKCE。
+//
+//	go run ./cmd/mockoidc -addr :9999 -username alice -email alice@example.com -groups admins
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"backend/internal/auth/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9999", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer，必须与后端 OIDC_ISSUER 一致")
+	clientID := flag.String("client-id", "go-admin", "客户端 ID")
+	clientSecret := flag.String("client-secret", "secret", "客户端密钥")
+	subject := flag.String("sub", "mock-user-1", "用户 subject")
+	username := flag.String("username", "alice", "preferred_username 声明")
+	email := flag.String("email", "alice@example.com", "email 声明")
+	emailVerified := flag.Bool("email-verified", true, "email_verified 声明")
+	groups := flag.String("groups", "", "groups 声明，逗号分隔")
+	flag.Parse()
+
+	groupList := []string{}
+	if *groups != "" {
+		groupList = strings.Split(*groups, ",")
+	}
+
+	provider, err := oidctest.New(*issuer, *clientID, *clientSecret, map[string]interface{}{
+		"sub":                *subject,
+		"preferred_username": *username,
+		"email":              *email,
+		"email_verified":     *emailVerified,
+		"groups":             groupList,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create provider: %v", err)
+	}
+
+	log.Printf("Mock OIDC provider %s listening on %s", provider.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, provider))
+}
@@ -0,0 +1,74 @@
+// Package auth 实现可插拔的用户名密码认证链
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+
+	"backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownUser 认证器不负责该用户，交给下一个认证器
+	ErrUnknownUser = errors.New("user not handled by authenticator")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUnavailable 认证后端暂时不可用
+	ErrUnavailable = errors.New("authentication backend unavailable")
+)
+
+// dummyPasswordHash 没有认证器认识该用户时也执行一次密码比对，避免通过响应时间判断用户名是否存在
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Authenticator 用户名密码认证器
+type Authenticator interface {
+	Name() string
+	// Authenticate 认证成功时返回对应的本地用户，不负责该用户时返回 ErrUnknownUser
+	Authenticate(ctx context.Context, login, password string) (*models.User, error)
+}
+
+// Chain 按顺序尝试的认证器，第一个认识该用户的认证器给出最终结果
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, login, password string) (*models.User, error) {
+	var unavailable error
+	for _, a := range c {
+		user, err := a.Authenticate(ctx, login, password)
+		switch {
+		case err == nil:
+			return user, nil
+		case errors.Is(err, ErrUnknownUser):
+			continue
+		case errors.Is(err, ErrUnavailable):
+			log.Printf("Authenticator %s unavailable: %v", a.Name(), err)
+			unavailable = err
+			continue
+		default:
+			return nil, err
+		}
+	}
+
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// UnusablePasswordHash 为外部身份源的用户生成无法用于本地登录的密码哈希
+func UnusablePasswordHash() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(b)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
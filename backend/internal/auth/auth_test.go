@@ -0,0 +1,275 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/auth/ldaptest"
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "Zx-98765!qwe"
+
+// newTestDB 初始化临时数据库，包含默认角色和 admin 用户
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("DATA_PATH", t.TempDir())
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func createUser(t *testing.T, db *gorm.DB, user models.User) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user.Password = string(hash)
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+// startLDAP 在随机端口启动内存目录服务器，返回连接它的认证器
+func startLDAP(t *testing.T, db *gorm.DB, users ...ldaptest.User) (*ldaptest.Server, *auth.LDAP) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pick port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	server, err := ldaptest.Start(addr, users...)
+	if err != nil {
+		t.Fatalf("ldaptest.Start: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	return server, auth.NewLDAP(db, auth.LDAPConfig{
+		URL:               "ldap://" + addr,
+		BindDN:            ldaptest.AdminDN,
+		BindPassword:      ldaptest.AdminPassword,
+		BaseDN:            ldaptest.BaseDN,
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		GroupAttribute:    "memberOf",
+		AdminGroup:        ldaptest.GroupDN("admins"),
+		Timeout:           5 * time.Second,
+	})
+}
+
+func userRoles(t *testing.T, db *gorm.DB, user *models.User) []string {
+	t.Helper()
+	if err := models.LoadUserRoles(db, user); err != nil {
+		t.Fatalf("LoadUserRoles: %v", err)
+	}
+	return user.Roles
+}
+
+func TestLocalAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	email := "bob@example.com"
+	createUser(t, db, models.User{Username: "bob", Email: &email})
+	createUser(t, db, models.User{Username: "ci-bot", AccountType: models.AccountTypeService})
+	createUser(t, db, models.User{Username: "carol", Source: models.UserSourceLDAP})
+	local := auth.NewLocal(db)
+	ctx := context.Background()
+
+	for _, login := range []string{"bob", " BOB@example.com "} {
+		user, err := local.Authenticate(ctx, login, testPassword)
+		if err != nil || user.Username != "bob" {
+			t.Fatalf("login %q: user %v, err %v", login, user, err)
+		}
+	}
+
+	tests := []struct {
+		login, password string
+		want            error
+	}{
+		{"bob", "wrong-password", auth.ErrInvalidCredentials},
+		{"nobody", testPassword, auth.ErrUnknownUser},
+		{"carol", testPassword, auth.ErrUnknownUser}, // 交给 LDAP 认证器
+		{"ci-bot", testPassword, auth.ErrInvalidCredentials},
+		{"ci-bot", "wrong-password", auth.ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		if _, err := local.Authenticate(ctx, tt.login, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("login %q/%q: got %v, want %v", tt.login, tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestLDAPAuthenticateCreatesShadowUser(t *testing.T) {
+	db := newTestDB(t)
+	_, ldap := startLDAP(t, db,
+		ldaptest.User{UID: "alice", Password: "alice-pass", Mail: "Alice@Example.com", Groups: []string{"admins"}},
+		ldaptest.User{UID: "dave", Password: "dave-pass"},
+	)
+	ctx := context.Background()
+
+	user, err := ldap.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Source != models.UserSourceLDAP || user.Email == nil || *user.Email != "alice@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected shadow user: %+v", user)
+	}
+	if roles := userRoles(t, db, user); len(roles) != 1 || roles[0] != models.RoleAdmin {
+		t.Fatalf("admin group member should get the admin role, got %v", roles)
+	}
+
+	// 影子用户的密码不能用于本地登录
+	if _, err := auth.NewLocal(db).Authenticate(ctx, "alice", "alice-pass"); !errors.Is(err, auth.ErrUnknownUser) {
+		t.Fatalf("local login of LDAP user: %v", err)
+	}
+
+	user, err = ldap.Authenticate(ctx, "dave", "dave-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Email != nil || len(userRoles(t, db, user)) != 0 {
+		t.Fatalf("user without mail or groups: %+v, roles %v", user, user.Roles)
+	}
+
+	tests := []struct {
+		login, password string
+		want            error
+	}{
+		{"alice", "wrong-password", auth.ErrInvalidCredentials},
+		{"alice", "", auth.ErrInvalidCredentials}, // 空密码会被当作匿名绑定
+		{"nobody", "whatever", auth.ErrUnknownUser},
+		{"*", "alice-pass", auth.ErrUnknownUser}, // 登录名中的过滤器字符被转义
+	}
+	for _, tt := range tests {
+		if _, err := ldap.Authenticate(ctx, tt.login, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("login %q/%q: got %v, want %v", tt.login, tt.password, err, tt.want)
+		}
+	}
+
+	var count int64
+	db.Model(&models.User{}).Where("source = ?", models.UserSourceLDAP).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 shadow users, got %d", count)
+	}
+}
+
+func TestLDAPAuthenticateSyncsAttributesAndAdminGroup(t *testing.T) {
+	db := newTestDB(t)
+	server, ldap := startLDAP(t, db,
+		ldaptest.User{UID: "alice", Password: "alice-pass", Mail: "alice@example.com", Groups: []string{"admins", "staff"}},
+	)
+	ctx := context.Background()
+
+	user, err := ldap.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	// 管理员手动分配的其他角色不受组同步影响
+	if err := db.Create(&models.Role{Name: "auditor"}).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	if _, err := models.AssignRole(db, user.ID, "auditor", true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+
+	server.SetUser(ldaptest.User{UID: "alice", Password: "new-pass", Mail: "alice@corp.example.com", Groups: []string{"staff"}})
+	if _, err := ldap.Authenticate(ctx, "alice", "alice-pass"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("old directory password: %v", err)
+	}
+	synced, err := ldap.Authenticate(ctx, "alice", "new-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if synced.ID != user.ID || synced.Email == nil || *synced.Email != "alice@corp.example.com" {
+		t.Fatalf("email was not synced: %+v", synced)
+	}
+	if roles := userRoles(t, db, synced); len(roles) != 1 || roles[0] != "auditor" {
+		t.Fatalf("admin role should be removed and other roles kept, got %v", roles)
+	}
+
+	var events []models.SecurityEvent
+	db.Where("user_id = ? AND type = ?", user.ID, models.SecurityEventRoleChanged).Find(&events)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 role change event, got %d", len(events))
+	}
+
+	// 重新加入管理员组
+	server.SetUser(ldaptest.User{UID: "alice", Password: "new-pass", Mail: "alice@corp.example.com", Groups: []string{"admins"}})
+	if synced, err = ldap.Authenticate(ctx, "alice", "new-pass"); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if roles := userRoles(t, db, synced); len(roles) != 2 {
+		t.Fatalf("admin role should be restored, got %v", roles)
+	}
+}
+
+func TestLDAPDoesNotTakeOverLocalUser(t *testing.T) {
+	db := newTestDB(t)
+	taken := "alice@example.com"
+	createUser(t, db, models.User{Username: "bob", Email: &taken})
+	_, ldap := startLDAP(t, db,
+		ldaptest.User{UID: "admin", Password: "admin-pass", Groups: []string{"admins"}},
+		ldaptest.User{UID: "alice", Password: "alice-pass", Mail: taken},
+	)
+	ctx := context.Background()
+
+	if _, err := ldap.Authenticate(ctx, "admin", "admin-pass"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("directory user with a local username: %v", err)
+	}
+
+	// 邮箱已被本地用户使用时不同步邮箱
+	user, err := ldap.Authenticate(ctx, "alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Email != nil {
+		t.Fatalf("email used by another user must not be synced: %s", *user.Email)
+	}
+}
+
+func TestChainFallsBackToLDAP(t *testing.T) {
+	db := newTestDB(t)
+	createUser(t, db, models.User{Username: "bob"})
+	server, ldap := startLDAP(t, db, ldaptest.User{UID: "alice", Password: "alice-pass"})
+	chain := auth.Chain{auth.NewLocal(db), ldap}
+	ctx := context.Background()
+
+	if user, err := chain.Authenticate(ctx, "bob", testPassword); err != nil || user.Source != models.UserSourceLocal {
+		t.Fatalf("local user: %v, %v", user, err)
+	}
+	if user, err := chain.Authenticate(ctx, "alice", "alice-pass"); err != nil || user.Source != models.UserSourceLDAP {
+		t.Fatalf("LDAP user: %v, %v", user, err)
+	}
+	if _, err := chain.Authenticate(ctx, "nobody", "whatever"); !errors.Is(err, auth.ErrInvalidCredentials) {
+		t.Fatalf("unknown user: %v", err)
+	}
+
+	// 目录不可用时本地用户仍可登录，其他用户得到 ErrUnavailable
+	server.Stop()
+	if _, err := chain.Authenticate(ctx, "bob", testPassword); err != nil {
+		t.Fatalf("local user with LDAP down: %v", err)
+	}
+	if _, err := chain.Authenticate(ctx, "alice", "alice-pass"); !errors.Is(err, auth.ErrUnavailable) {
+		t.Fatalf("LDAP user with LDAP down: %v", err)
+	}
+}
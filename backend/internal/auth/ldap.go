@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+// LDAPConfig LDAP / Active Directory 连接配置
+type LDAPConfig struct {
+	URL                string // 如 ldap://ldap.example.com:389 或 ldaps://ldap.example.com:636
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string // 用于查找用户的服务账户，为空时匿名查找
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // %s 会被替换为转义后的登录名，如 (uid=%s) 或 (sAMAccountName=%s)
+	UsernameAttribute  string
+	EmailAttribute     string
+	GroupAttribute     string // 用户所属组的属性，如 memberOf
+	AdminGroup         string // 属于该组的用户为管理员，为空时不同步管理员权限
+	Timeout            time.Duration
+}
+
+// LDAP 先用服务账户查找用户，再以用户 DN 和密码绑定完成认证；首次登录时创建本地影子用户
+type LDAP struct {
+	db  *gorm.DB
+	cfg LDAPConfig
+}
+
+func NewLDAP(db *gorm.DB, cfg LDAPConfig) *LDAP {
+	return &LDAP{db: db, cfg: cfg}
+}
+
+func (l *LDAP) Name() string {
+	return models.UserSourceLDAP
+}
+
+func (l *LDAP) Authenticate(ctx context.Context, login, password string) (*models.User, error) {
+	// 空密码会被服务器当作匿名绑定而成功
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := l.findUser(conn, login)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	return l.syncUser(ctx, entry)
+}
+
+// connect 连接服务器并以服务账户绑定
+func (l *LDAP) connect() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.cfg.InsecureSkipVerify}
+	conn, err := ldap.DialURL(l.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: l.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	conn.SetTimeout(l.cfg.Timeout)
+
+	if l.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+	}
+
+	if l.cfg.BindDN != "" {
+		err = conn.Bind(l.cfg.BindDN, l.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: service bind: %v", ErrUnavailable, err)
+	}
+	return conn, nil
+}
+
+// findUser 按过滤条件查找唯一的用户条目
+func (l *LDAP) findUser(conn *ldap.Conn, login string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(l.cfg.UserFilter, "%s", ldap.EscapeFilter(login))
+	request := ldap.NewSearchRequest(
+		l.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(l.cfg.Timeout.Seconds()), false,
+		filter,
+		[]string{l.cfg.UsernameAttribute, l.cfg.EmailAttribute, l.cfg.GroupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrUnknownUser
+		}
+		return nil, fmt.Errorf("%w: search: %v", ErrUnavailable, err)
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, ErrUnknownUser
+	}
+	if len(result.Entries) > 1 {
+		log.Printf("LDAP filter %s matched multiple entries", filter)
+		return nil, ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// syncUser 创建或更新影子用户，同步邮箱和管理员权限
+func (l *LDAP) syncUser(ctx context.Context, entry *ldap.Entry) (*models.User, error) {
+	username := entry.GetAttributeValue(l.cfg.UsernameAttribute)
+	if username == "" {
+		return nil, fmt.Errorf("ldap entry %s has no %s attribute", entry.DN, l.cfg.UsernameAttribute)
+	}
+	email := strings.ToLower(strings.TrimSpace(entry.GetAttributeValue(l.cfg.EmailAttribute)))
+	isAdmin := l.isAdmin(entry)
+
+	var user models.User
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 邮箱已被其他用户使用时不同步邮箱
+		var emailPtr *string
+		if email != "" {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ? AND username <> ?", email, username).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				emailPtr = &email
+			}
+		}
+
+		err := tx.Where("username = ?", username).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			hash, err := UnusablePasswordHash()
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			user = models.User{
+				Username: username,
+				Password: hash,
+				Email:    emailPtr,
+				Source:   models.UserSourceLDAP,
+			}
+			if emailPtr != nil {
+				user.EmailVerifiedAt = &now
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if isAdmin != nil && *isAdmin {
+				_, err := models.AssignRole(tx, user.ID, models.RoleAdmin, true)
+				return err
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 同名的本地或其他来源用户不能被目录中的账户接管
+		if user.Source != models.UserSourceLDAP {
+			log.Printf("LDAP user %s conflicts with existing %s user", username, user.Source)
+			return ErrInvalidCredentials
+		}
+
+		updates := map[string]interface{}{}
+		if emailPtr != nil && (user.Email == nil || *user.Email != email) {
+			updates["email"] = email
+			updates["email_verified_at"] = time.Now()
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		// 管理员组只决定 admin 角色，管理员手动分配的其他角色不受影响
+		if isAdmin == nil {
+			return nil
+		}
+		changed, err := models.AssignRole(tx, user.ID, models.RoleAdmin, *isAdmin)
+		if err != nil || !changed {
+			return err
+		}
+		return tx.Create(&models.SecurityEvent{
+			UserID:   &user.ID,
+			Username: user.Username,
+			Type:     models.SecurityEventRoleChanged,
+			Detail:   models.RoleChangeDetail(models.RoleAdmin, *isAdmin) + "（LDAP 组同步）",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// isAdmin 未配置管理员组时返回 nil，表示不修改管理员权限
+func (l *LDAP) isAdmin(entry *ldap.Entry) *bool {
+	if l.cfg.AdminGroup == "" {
+		return nil
+	}
+	isAdmin := false
+	for _, group := range entry.GetAttributeValues(l.cfg.GroupAttribute) {
+		if strings.EqualFold(group, l.cfg.AdminGroup) {
+			isAdmin = true
+			break
+		}
+	}
+	return &isAdmin
+}
+
+// TestResult 连接测试结果
+type TestResult struct {
+	Connected  bool                `json:"connected"`
+	UserFound  bool                `json:"user_found"`
+	UserDN     string              `json:"user_dn,omitempty"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+	UserBind   *bool               `json:"user_bind,omitempty"`
+	IsAdmin    *bool               `json:"is_admin,omitempty"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// Test 测试服务器连接和服务账户绑定；提供用户名时测试用户查找，提供密码时测试用户绑定。不会创建或修改本地用户
+func (l *LDAP) Test(login, password string) TestResult {
+	var result TestResult
+
+	conn, err := l.connect()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+	result.Connected = true
+
+	if login == "" {
+		return result
+	}
+
+	entry, err := l.findUser(conn, login)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.UserFound = true
+	result.UserDN = entry.DN
+	result.Attributes = map[string][]string{}
+	for _, attr := range entry.Attributes {
+		result.Attributes[attr.Name] = attr.Values
+	}
+	result.IsAdmin = l.isAdmin(entry)
+
+	if password != "" {
+		ok := conn.Bind(entry.DN, password) == nil
+		result.UserBind = &ok
+	}
+	return result
+}
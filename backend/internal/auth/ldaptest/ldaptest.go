@@ -0,0 +1,254 @@
+// Package ldaptest 提供一个内存中的 LDAP 目录服务器，用于开发和测试 LDAP 认证，
+// 支持简单绑定和按 (属性=值) 条件的子树查找
+package ldaptest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+)
+
+const (
+	// BaseDN 目录的根
+	BaseDN = "dc=example,dc=com"
+	// AdminDN 服务账户 DN
+	AdminDN = "cn=admin," + BaseDN
+	// AdminPassword 服务账户密码
+	AdminPassword = "admin"
+)
+
+// User 目录中的用户
+type User struct {
+	UID      string
+	Password string
+	Mail     string
+	Groups   []string // 组名，memberOf 为 cn=<组名>,ou=groups,<BaseDN>
+}
+
+// DN 用户条目的 DN
+func (u User) DN() string {
+	return fmt.Sprintf("uid=%s,ou=people,%s", u.UID, BaseDN)
+}
+
+// GroupDN 组条目的 DN
+func GroupDN(name string) string {
+	return fmt.Sprintf("cn=%s,ou=groups,%s", name, BaseDN)
+}
+
+func (u User) attributes() map[string][]string {
+	groups := make([]string, 0, len(u.Groups))
+	for _, g := range u.Groups {
+		groups = append(groups, GroupDN(g))
+	}
+	attrs := map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {u.UID},
+		"cn":          {u.UID},
+		"memberOf":    groups,
+	}
+	if u.Mail != "" {
+		attrs["mail"] = []string{u.Mail}
+	}
+	return attrs
+}
+
+// Server 内存 LDAP 服务器
+type Server struct {
+	srv *gldap.Server
+
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// Start 在 addr 上启动服务器，如 127.0.0.1:3890
+func Start(addr string, users ...User) (*Server, error) {
+	s := &Server{users: make(map[string]User)}
+	for _, u := range users {
+		s.users[u.UID] = u
+	}
+
+	srv, err := gldap.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	mux, err := gldap.NewMux()
+	if err != nil {
+		return nil, err
+	}
+	mux.Bind(s.bind)
+	mux.Search(s.search)
+	mux.DefaultRoute(s.unsupported)
+	srv.Router(mux)
+	s.srv = srv
+
+	errs := make(chan error, 1)
+	go func() { errs <- srv.Run(addr) }()
+
+	// 等待监听就绪
+	deadline := time.Now().Add(5 * time.Second)
+	for !srv.Ready() {
+		select {
+		case err := <-errs:
+			return nil, err
+		default:
+		}
+		if time.Now().After(deadline) {
+			srv.Stop()
+			return nil, fmt.Errorf("ldaptest: server did not start on %s", addr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s, nil
+}
+
+// Stop 停止服务器
+func (s *Server) Stop() error {
+	return s.srv.Stop()
+}
+
+// SetUser 新增或替换用户，可用于模拟目录中属性和组的变化
+func (s *Server) SetUser(u User) {
+	s.mu.Lock()
+	s.users[u.UID] = u
+	s.mu.Unlock()
+}
+
+// RemoveUser 删除用户
+func (s *Server) RemoveUser(uid string) {
+	s.mu.Lock()
+	delete(s.users, uid)
+	s.mu.Unlock()
+}
+
+func (s *Server) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer func() { w.Write(resp) }()
+
+	msg, err := r.GetSimpleBindMessage()
+	if err != nil {
+		return
+	}
+	password := string(msg.Password)
+
+	// 匿名绑定
+	if msg.UserName == "" && password == "" {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	if strings.EqualFold(msg.UserName, AdminDN) && password == AdminPassword {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if strings.EqualFold(msg.UserName, u.DN()) && password != "" && password == u.Password {
+			resp.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+	}
+}
+
+func (s *Server) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultNoSuchObject))
+	defer func() { w.Write(resp) }()
+
+	msg, err := r.GetSearchMessage()
+	if err != nil {
+		return
+	}
+	if !strings.HasSuffix(strings.ToLower(msg.BaseDN), strings.ToLower(BaseDN)) {
+		return
+	}
+
+	conditions := parseFilter(msg.Filter)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		attrs := u.attributes()
+		if !strings.HasSuffix(strings.ToLower(u.DN()), strings.ToLower(msg.BaseDN)) || !matches(attrs, conditions) {
+			continue
+		}
+		w.Write(r.NewSearchResponseEntry(u.DN(), gldap.WithAttributes(selectAttributes(attrs, msg.Attributes))))
+	}
+	resp.SetResultCode(gldap.ResultSuccess)
+}
+
+func (s *Server) unsupported(w *gldap.ResponseWriter, r *gldap.Request) {
+	w.Write(r.NewResponse(gldap.WithResponseCode(gldap.ResultUnwillingToPerform)))
+}
+
+var conditionPattern = regexp.MustCompile(`\(([A-Za-z][A-Za-z0-9-]*)=([^()]*)\)`)
+
+// parseFilter 提取过滤条件中所有的 (属性=值)，按“与”处理；足以覆盖 (uid=%s) 和 (&(objectClass=x)(uid=%s)) 形式的过滤条件
+func parseFilter(filter string) map[string]*string {
+	conditions := map[string]*string{}
+	for _, m := range conditionPattern.FindAllStringSubmatch(filter, -1) {
+		// 未转义的 * 表示属性存在即可，转义后的 \2a 是字面量
+		if m[2] == "*" {
+			conditions[strings.ToLower(m[1])] = nil
+			continue
+		}
+		value := unescapeFilterValue(m[2])
+		conditions[strings.ToLower(m[1])] = &value
+	}
+	return conditions
+}
+
+// unescapeFilterValue 还原 RFC 4515 的 \xx 转义
+func unescapeFilterValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+2 < len(value) {
+			if decoded, err := hex.DecodeString(value[i+1 : i+3]); err == nil {
+				b.Write(decoded)
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+func matches(attrs map[string][]string, conditions map[string]*string) bool {
+	for name, want := range conditions {
+		found := false
+		for key, values := range attrs {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			for _, v := range values {
+				if want == nil || strings.EqualFold(v, *want) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// selectAttributes 只返回请求的属性，未指定时返回全部
+func selectAttributes(attrs map[string][]string, requested []string) map[string][]string {
+	if len(requested) == 0 {
+		return attrs
+	}
+	selected := map[string][]string{}
+	for _, name := range requested {
+		for key, values := range attrs {
+			if strings.EqualFold(key, name) && len(values) > 0 {
+				selected[key] = values
+			}
+		}
+	}
+	return selected
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Local 使用数据库中的 bcrypt 密码认证本地用户
+type Local struct {
+	db *gorm.DB
+}
+
+func NewLocal(db *gorm.DB) *Local {
+	return &Local{db: db}
+}
+
+func (l *Local) Name() string {
+	return models.UserSourceLocal
+}
+
+// Authenticate 支持用户名或邮箱登录，外部身份源的用户交给其他认证器，服务账户不能登录
+func (l *Local) Authenticate(ctx context.Context, login, password string) (*models.User, error) {
+	var user models.User
+	err := l.db.WithContext(ctx).
+		Where("username = ? OR email = ?", login, strings.ToLower(strings.TrimSpace(login))).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.Source != models.UserSourceLocal {
+		return nil, ErrUnknownUser
+	}
+
+	// 先比对密码再拒绝服务账户，避免通过响应时间判断账户类型
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	// 服务账户只能使用 API 凭据
+	if user.IsServiceAccount() {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
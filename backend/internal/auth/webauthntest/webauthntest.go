@@ -0,0 +1,229 @@
+// Package webauthntest 提供一个软件实现的通行密钥认证器，用于测试注册和登录仪式。
+// 它生成 P-256 凭据，使用 none 格式的证明，并在每次断言时递增签名计数。
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// 认证器数据中的标志位：用户在场、用户已验证、包含凭据数据
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagAttestedCredID = 0x40
+)
+
+// credential 认证器中保存的可发现凭据
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	rpID       string
+	userHandle []byte
+	signCount  uint32
+}
+
+// Authenticator 软件认证器，Origin 为发起仪式的页面来源，必须在依赖方允许的来源中
+type Authenticator struct {
+	Origin      string
+	credentials []*credential
+}
+
+// New 创建没有任何凭据的认证器
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+// Clone 复制认证器及其密钥和签名计数，模拟被克隆的认证器
+func (a *Authenticator) Clone() *Authenticator {
+	clone := &Authenticator{Origin: a.Origin}
+	for _, cred := range a.credentials {
+		copied := *cred
+		clone.credentials = append(clone.credentials, &copied)
+	}
+	return clone
+}
+
+// creationOptions navigator.credentials.create 的参数中认证器需要的部分
+type creationOptions struct {
+	PublicKey struct {
+		Challenge protocol.URLEncodedBase64 `json:"challenge"`
+		RP        struct {
+			ID string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			ID protocol.URLEncodedBase64 `json:"id"`
+		} `json:"user"`
+	} `json:"publicKey"`
+}
+
+// requestOptions navigator.credentials.get 的参数中认证器需要的部分
+type requestOptions struct {
+	PublicKey struct {
+		Challenge        protocol.URLEncodedBase64 `json:"challenge"`
+		RPID             string                    `json:"rpId"`
+		AllowCredentials []struct {
+			ID protocol.URLEncodedBase64 `json:"id"`
+		} `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+// Register 根据依赖方的注册参数创建新凭据，返回可直接提交给依赖方的注册响应
+func (a *Authenticator) Register(options []byte) ([]byte, error) {
+	var opts creationOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	cred := &credential{
+		id:         make([]byte, 32),
+		key:        key,
+		rpID:       opts.PublicKey.RP.ID,
+		userHandle: opts.PublicKey.User.ID,
+	}
+	if _, err := rand.Read(cred.id); err != nil {
+		return nil, err
+	}
+
+	publicKey, err := cosePublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	authData := authenticatorData(cred.rpID, flagUserPresent|flagUserVerified|flagAttestedCredID, cred.signCount)
+	authData = append(authData, make([]byte, 16)...) // AAGUID，none 证明为全 0
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(cred.id)))
+	authData = append(authData, cred.id...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientData, err := a.clientData("webauthn.create", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, cred)
+	return json.Marshal(map[string]interface{}{
+		"id":    protocol.URLEncodedBase64(cred.id),
+		"rawId": protocol.URLEncodedBase64(cred.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    protocol.URLEncodedBase64(clientData),
+			"attestationObject": protocol.URLEncodedBase64(attestation),
+		},
+	})
+}
+
+// Login 根据依赖方的认证参数生成断言；allowCredentials 为空时按可发现凭据处理，使用该依赖方最近注册的凭据
+func (a *Authenticator) Login(options []byte) ([]byte, error) {
+	var opts requestOptions
+	if err := json.Unmarshal(options, &opts); err != nil {
+		return nil, err
+	}
+
+	cred := a.find(opts)
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+
+	cred.signCount++
+	authData := authenticatorData(cred.rpID, flagUserPresent|flagUserVerified, cred.signCount)
+	clientData, err := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    protocol.URLEncodedBase64(cred.id),
+		"rawId": protocol.URLEncodedBase64(cred.id),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    protocol.URLEncodedBase64(clientData),
+			"authenticatorData": protocol.URLEncodedBase64(authData),
+			"signature":         protocol.URLEncodedBase64(signature),
+			"userHandle":        protocol.URLEncodedBase64(cred.userHandle),
+		},
+	})
+}
+
+func (a *Authenticator) find(opts requestOptions) *credential {
+	for i := len(a.credentials) - 1; i >= 0; i-- {
+		cred := a.credentials[i]
+		if cred.rpID != opts.PublicKey.RPID {
+			continue
+		}
+		if len(opts.PublicKey.AllowCredentials) == 0 {
+			return cred
+		}
+		for _, allowed := range opts.PublicKey.AllowCredentials {
+			if bytes.Equal(allowed.ID, cred.id) {
+				return cred
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// authenticatorData 不含凭据数据的认证器数据：RP ID 哈希、标志位和签名计数
+func authenticatorData(rpID string, flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+// cosePublicKey 将公钥编码为 COSE_Key 格式
+func cosePublicKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	pub, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	point := pub.Bytes() // 0x04 || X || Y
+	if len(point) != 65 {
+		return nil, errors.New("webauthntest: unexpected public key encoding")
+	}
+	return webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+}
@@ -0,0 +1,174 @@
+// Package captcha 生成不依赖外部服务的图形验证码：算术题或随机字符，渲染为带干扰的 PNG 图片
+package captcha
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+	mathrand "math/rand"
+	"strconv"
+	"strings"
+)
+
+// 验证码类型
+const (
+	TypeMath = "math" // 两位数以内的加减法，答案为计算结果
+	TypeText = "text" // 随机字符，答案不区分大小写
+)
+
+// textAlphabet 随机字符验证码使用的字符，去掉了容易混淆的 0、1、I、L、O
+const textAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// textLength 随机字符验证码的长度
+const textLength = 5
+
+// Challenge 一道验证码题目
+type Challenge struct {
+	Text   string // 图片中显示的内容
+	Answer string // 规范化后的正确答案
+}
+
+// New 按类型生成题目，未知类型按算术题处理
+func New(kind string) (Challenge, error) {
+	if kind == TypeText {
+		return newText()
+	}
+	return newMath()
+}
+
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+func newMath() (Challenge, error) {
+	a, err := randomInt(20)
+	if err != nil {
+		return Challenge{}, err
+	}
+	b, err := randomInt(20)
+	if err != nil {
+		return Challenge{}, err
+	}
+	subtract, err := randomInt(2)
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	// 减法时保证结果不为负数
+	if subtract == 1 {
+		if a < b {
+			a, b = b, a
+		}
+		return Challenge{Text: fmt.Sprintf("%d-%d=?", a, b), Answer: strconv.Itoa(a - b)}, nil
+	}
+	return Challenge{Text: fmt.Sprintf("%d+%d=?", a, b), Answer: strconv.Itoa(a + b)}, nil
+}
+
+func newText() (Challenge, error) {
+	var sb strings.Builder
+	for i := 0; i < textLength; i++ {
+		n, err := randomInt(len(textAlphabet))
+		if err != nil {
+			return Challenge{}, err
+		}
+		sb.WriteByte(textAlphabet[n])
+	}
+	return Challenge{Text: sb.String(), Answer: sb.String()}, nil
+}
+
+// Normalize 规范化用户输入的答案，去掉空白并转为大写
+func Normalize(answer string) string {
+	return strings.ToUpper(strings.TrimSpace(answer))
+}
+
+// 渲染参数
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+	scale       = 4
+	spacing     = 6
+	padding     = 10
+	imageHeight = glyphHeight*scale + 2*padding
+)
+
+// Render 将题目渲染为 PNG 图片，字符位置和颜色随机抖动，并加入干扰线和噪点
+func Render(text string) ([]byte, error) {
+	width := 2*padding + len(text)*(glyphWidth*scale+spacing) - spacing
+	img := image.NewRGBA(image.Rect(0, 0, width, imageHeight))
+
+	background := color.RGBA{R: uint8(225 + mathrand.Intn(30)), G: uint8(225 + mathrand.Intn(30)), B: uint8(225 + mathrand.Intn(30)), A: 255}
+	for y := 0; y < imageHeight; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, background)
+		}
+	}
+
+	for i, ch := range text {
+		glyph, ok := font[ch]
+		if !ok {
+			continue
+		}
+		x0 := padding + i*(glyphWidth*scale+spacing) + mathrand.Intn(5) - 2
+		y0 := padding + mathrand.Intn(7) - 3
+		ink := randomInk()
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits[col] != '1' {
+					continue
+				}
+				fillRect(img, x0+col*scale, y0+row*scale, scale, scale, ink)
+			}
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		drawLine(img, mathrand.Intn(width), mathrand.Intn(imageHeight), mathrand.Intn(width), mathrand.Intn(imageHeight), randomInk())
+	}
+	for i := 0; i < width*imageHeight/30; i++ {
+		img.Set(mathrand.Intn(width), mathrand.Intn(imageHeight), randomInk())
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func randomInk() color.RGBA {
+	return color.RGBA{R: uint8(mathrand.Intn(120)), G: uint8(mathrand.Intn(120)), B: uint8(mathrand.Intn(120)), A: 255}
+}
+
+func fillRect(img *image.RGBA, x, y, w, h int, c color.RGBA) {
+	for dy := 0; dy < h; dy++ {
+		for dx := 0; dx < w; dx++ {
+			img.Set(x+dx, y+dy, c)
+		}
+	}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	steps := max(abs(x1-x0), abs(y1-y0))
+	for i := 0; i <= steps; i++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(i) / float64(steps)
+		}
+		img.Set(x0+int(t*float64(x1-x0)), y0+int(t*float64(y1-y0)), c)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -1,25 +1,104 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 令牌传递方式
+const (
+	AuthModeHeader = "header" // 令牌由前端保存，通过 Authorization 请求头发送
+	AuthModeCookie = "cookie" // 令牌保存在 HttpOnly Cookie 中，修改类请求需要携带 CSRF 令牌
 )
 
 type Config struct {
 	ServerAddress string `json:"server_address"`
-	JWTSecret    string `json:"jwt_secret"`
+	JWTSecret    string `json:"jwt_secret"` // 仅在 JWTAlgorithm 为 HS256 时使用，为空时自动生成
 	DataPath     string `json:"data_path"`
 	DbName       string `json:"db_name"`
 	DatabasePath string `json:"database_path"` // 完整的数据库路径
+	AccessTokenTTL  time.Duration `json:"access_token_ttl"`  // 访问令牌有效期
+	RefreshTokenTTL time.Duration `json:"refresh_token_ttl"` // 刷新令牌有效期
+	JWTAlgorithm    string        `json:"jwt_algorithm"`     // 签名算法：HS256、RS256 或 EdDSA
+	JWTIssuer       string        `json:"jwt_issuer"`
+	JWTAudience     string        `json:"jwt_audience"`
+	JWTKeyGrace     time.Duration `json:"jwt_key_grace"` // 密钥轮换后旧密钥继续验证的时长
+	WebAuthnRPID      string   `json:"webauthn_rp_id"`      // 通行密钥依赖方 ID，通常为站点域名
+	WebAuthnRPOrigins []string `json:"webauthn_rp_origins"` // 允许发起通行密钥认证的来源
+	PublicURL         string   `json:"public_url"`          // 前端访问地址，用于生成邮件和重置链接
+	SMTPHost          string   `json:"smtp_host"`           // 未配置时邮件写入 MailSinkPath 或日志
+	SMTPPort          int      `json:"smtp_port"`
+	SMTPUsername      string   `json:"smtp_username"`
+	SMTPPassword      string   `json:"smtp_password"`
+	MailFrom          string   `json:"mail_from"`
+	MailSinkPath      string   `json:"mail_sink_path"` // 开发和测试时保存邮件的文件
+	// OIDC 单点登录，配置 OIDCIssuer 和 OIDCClientID 后启用
+	OIDCIssuer        string   `json:"oidc_issuer"`
+	OIDCClientID      string   `json:"oidc_client_id"`
+	OIDCClientSecret  string   `json:"oidc_client_secret"`
+	OIDCRedirectURL   string   `json:"oidc_redirect_url"` // 为空时使用 PublicURL + /api/oidc/callback
+	OIDCScopes        []string `json:"oidc_scopes"`
+	OIDCUsernameClaim string   `json:"oidc_username_claim"`
+	OIDCEmailClaim    string   `json:"oidc_email_claim"`
+	OIDCGroupsClaim   string   `json:"oidc_groups_claim"`
+	OIDCAdminGroup    string   `json:"oidc_admin_group"`    // 属于该组的用户为管理员，为空时不同步管理员权限
+	OIDCAutoProvision bool     `json:"oidc_auto_provision"` // 首次登录时自动创建用户
+	// LDAP 认证，配置 LDAPURL 后启用
+	LDAPURL                string `json:"ldap_url"`
+	LDAPStartTLS           bool   `json:"ldap_start_tls"`
+	LDAPInsecureSkipVerify bool   `json:"ldap_insecure_skip_verify"`
+	LDAPBindDN             string `json:"ldap_bind_dn"`
+	LDAPBindPassword       string `json:"ldap_bind_password"`
+	LDAPBaseDN             string `json:"ldap_base_dn"`
+	LDAPUserFilter         string `json:"ldap_user_filter"` // %s 替换为登录名
+	LDAPUsernameAttribute  string `json:"ldap_username_attribute"`
+	LDAPEmailAttribute     string `json:"ldap_email_attribute"`
+	LDAPGroupAttribute     string `json:"ldap_group_attribute"`
+	LDAPAdminGroup         string `json:"ldap_admin_group"` // 属于该组（DN）的用户为管理员
+	// 令牌传递方式与跨域
+	AuthMode           string   `json:"auth_mode"`            // header 或 cookie
+	CookieDomain       string   `json:"cookie_domain"`        // 为空时只对当前主机有效
+	CookieSecure       bool     `json:"cookie_secure"`        // 本地 HTTP 调试时可关闭
+	CookieSameSite     string   `json:"cookie_same_site"`     // strict、lax 或 none
+	CORSAllowedOrigins []string `json:"cors_allowed_origins"` // Cookie 模式下允许携带凭据的跨域来源，为空时使用 PublicURL 的来源
+	// 只信任这些代理（IP 或 CIDR）设置的 X-Forwarded-For，其他来源直接使用连接地址
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 func LoadConfig() *Config {
 	// 默认配置
 	config := &Config{
 		ServerAddress: ":8080",
-		JWTSecret:    "M6pTFnXmYKpH2k",
 		DataPath:     "data",
 		DbName:       "app.db",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+		JWTAlgorithm:    "RS256",
+		JWTIssuer:       "go-admin",
+		JWTAudience:     "go-admin",
+		JWTKeyGrace:     24 * time.Hour,
+		WebAuthnRPID:      "localhost",
+		WebAuthnRPOrigins: []string{"http://localhost:5173", "http://localhost:9876"},
+		PublicURL:         "http://localhost:9876",
+		SMTPPort:          587,
+		MailFrom:          "noreply@localhost",
+		OIDCScopes:        []string{"openid", "profile", "email"},
+		OIDCUsernameClaim: "preferred_username",
+		OIDCEmailClaim:    "email",
+		OIDCGroupsClaim:   "groups",
+		OIDCAutoProvision: true,
+		LDAPUserFilter:        "(uid=%s)",
+		LDAPUsernameAttribute: "uid",
+		LDAPEmailAttribute:    "mail",
+		LDAPGroupAttribute:    "memberOf",
+		AuthMode:              AuthModeHeader,
+		CookieSecure:          true,
+		CookieSameSite:        "lax",
+		TrustedProxies:        []string{"127.0.0.1", "::1"}, // Docker 镜像中 Nginx 与后端位于同一容器
 	}
 
 	// 从环境变量加载配置
@@ -36,6 +115,157 @@ func LoadConfig() *Config {
 		config.DbName = dbName
 	}
 
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		config.AccessTokenTTL = ttl
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		config.RefreshTokenTTL = ttl
+	}
+
+	if algorithm := os.Getenv("JWT_ALGORITHM"); algorithm != "" {
+		config.JWTAlgorithm = algorithm
+	}
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		config.JWTIssuer = issuer
+	}
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		config.JWTAudience = audience
+	}
+	if grace, err := time.ParseDuration(os.Getenv("JWT_KEY_GRACE")); err == nil && grace >= 0 {
+		config.JWTKeyGrace = grace
+	}
+
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		config.WebAuthnRPID = rpID
+	}
+	if origins := os.Getenv("WEBAUTHN_RP_ORIGINS"); origins != "" {
+		config.WebAuthnRPOrigins = strings.Split(origins, ",")
+	}
+
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		config.PublicURL = publicURL
+	}
+
+	if smtpHost := os.Getenv("SMTP_HOST"); smtpHost != "" {
+		config.SMTPHost = smtpHost
+	}
+	if port, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil && port > 0 {
+		config.SMTPPort = port
+	}
+	if smtpUsername := os.Getenv("SMTP_USERNAME"); smtpUsername != "" {
+		config.SMTPUsername = smtpUsername
+	}
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		config.SMTPPassword = smtpPassword
+	}
+	if mailFrom := os.Getenv("MAIL_FROM"); mailFrom != "" {
+		config.MailFrom = mailFrom
+	}
+	if sinkPath := os.Getenv("MAIL_SINK_PATH"); sinkPath != "" {
+		config.MailSinkPath = sinkPath
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		config.OIDCIssuer = issuer
+	}
+	if clientID := os.Getenv("OIDC_CLIENT_ID"); clientID != "" {
+		config.OIDCClientID = clientID
+	}
+	if clientSecret := os.Getenv("OIDC_CLIENT_SECRET"); clientSecret != "" {
+		config.OIDCClientSecret = clientSecret
+	}
+	if redirectURL := os.Getenv("OIDC_REDIRECT_URL"); redirectURL != "" {
+		config.OIDCRedirectURL = redirectURL
+	}
+	if scopes := os.Getenv("OIDC_SCOPES"); scopes != "" {
+		config.OIDCScopes = strings.Split(scopes, ",")
+	}
+	if claim := os.Getenv("OIDC_USERNAME_CLAIM"); claim != "" {
+		config.OIDCUsernameClaim = claim
+	}
+	if claim := os.Getenv("OIDC_EMAIL_CLAIM"); claim != "" {
+		config.OIDCEmailClaim = claim
+	}
+	if claim := os.Getenv("OIDC_GROUPS_CLAIM"); claim != "" {
+		config.OIDCGroupsClaim = claim
+	}
+	if group := os.Getenv("OIDC_ADMIN_GROUP"); group != "" {
+		config.OIDCAdminGroup = group
+	}
+	if autoProvision, err := strconv.ParseBool(os.Getenv("OIDC_AUTO_PROVISION")); err == nil {
+		config.OIDCAutoProvision = autoProvision
+	}
+
+	if ldapURL := os.Getenv("LDAP_URL"); ldapURL != "" {
+		config.LDAPURL = ldapURL
+	}
+	if startTLS, err := strconv.ParseBool(os.Getenv("LDAP_START_TLS")); err == nil {
+		config.LDAPStartTLS = startTLS
+	}
+	if skipVerify, err := strconv.ParseBool(os.Getenv("LDAP_INSECURE_SKIP_VERIFY")); err == nil {
+		config.LDAPInsecureSkipVerify = skipVerify
+	}
+	if bindDN := os.Getenv("LDAP_BIND_DN"); bindDN != "" {
+		config.LDAPBindDN = bindDN
+	}
+	if bindPassword := os.Getenv("LDAP_BIND_PASSWORD"); bindPassword != "" {
+		config.LDAPBindPassword = bindPassword
+	}
+	if baseDN := os.Getenv("LDAP_BASE_DN"); baseDN != "" {
+		config.LDAPBaseDN = baseDN
+	}
+	if filter := os.Getenv("LDAP_USER_FILTER"); filter != "" {
+		config.LDAPUserFilter = filter
+	}
+	if attr := os.Getenv("LDAP_USERNAME_ATTRIBUTE"); attr != "" {
+		config.LDAPUsernameAttribute = attr
+	}
+	if attr := os.Getenv("LDAP_EMAIL_ATTRIBUTE"); attr != "" {
+		config.LDAPEmailAttribute = attr
+	}
+	if attr := os.Getenv("LDAP_GROUP_ATTRIBUTE"); attr != "" {
+		config.LDAPGroupAttribute = attr
+	}
+	if group := os.Getenv("LDAP_ADMIN_GROUP"); group != "" {
+		config.LDAPAdminGroup = group
+	}
+
+	if mode := os.Getenv("AUTH_MODE"); mode == AuthModeHeader || mode == AuthModeCookie {
+		config.AuthMode = mode
+	}
+	if domain := os.Getenv("COOKIE_DOMAIN"); domain != "" {
+		config.CookieDomain = domain
+	}
+	if secure, err := strconv.ParseBool(os.Getenv("COOKIE_SECURE")); err == nil {
+		config.CookieSecure = secure
+	}
+	if sameSite := strings.ToLower(os.Getenv("COOKIE_SAMESITE")); sameSite == "strict" || sameSite == "lax" || sameSite == "none" {
+		config.CookieSameSite = sameSite
+	}
+	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
+		// 来源按原样与请求的 Origin 比较，去掉逗号两侧的空格和空项
+		for _, origin := range strings.Split(origins, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				config.CORSAllowedOrigins = append(config.CORSAllowedOrigins, origin)
+			}
+		}
+	}
+	if len(config.CORSAllowedOrigins) == 0 {
+		if u, err := url.Parse(config.PublicURL); err == nil && u.Host != "" {
+			config.CORSAllowedOrigins = []string{u.Scheme + "://" + u.Host}
+		}
+	}
+
+	if proxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		// 设为空时不信任任何代理
+		config.TrustedProxies = nil
+		for _, proxy := range strings.Split(proxies, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				config.TrustedProxies = append(config.TrustedProxies, proxy)
+			}
+		}
+	}
+
 	// 组合完整的数据库路径
 	config.DatabasePath = filepath.Join(config.DataPath, config.DbName)
 
@@ -0,0 +1,23 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example.com, ,https://b.example.com ,")
+	got := LoadConfig().CORSAllowedOrigins
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// 未配置时使用 PUBLIC_URL 的来源
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("PUBLIC_URL", "https://admin.example.com/app/")
+	got = LoadConfig().CORSAllowedOrigins
+	if !reflect.DeepEqual(got, []string{"https://admin.example.com"}) {
+		t.Fatalf("fallback origins: got %q", got)
+	}
+}
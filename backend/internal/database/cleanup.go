@@ -0,0 +1,115 @@
+package database
+
+import (
+	"log"
+	"time"
+
+	"backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// apiTokenRetention 已失效的 API 令牌的保留时间
+const apiTokenRetention = 90 * 24 * time.Hour
+
+// securityEventRetention 登录记录和安全事件的保留时间
+const securityEventRetention = 365 * 24 * time.Hour
+
+// StartCleanupTask 定期清理已过期的数据
+func StartCleanupTask(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			cleanupExpired(db)
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpired(db *gorm.DB) {
+	now := time.Now()
+
+	// 已过期的令牌无需继续保留吊销记录
+	if err := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		log.Printf("Failed to prune revoked tokens: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		log.Printf("Failed to prune refresh tokens: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.Session{}).Error; err != nil {
+		log.Printf("Failed to prune sessions: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.WebAuthnChallenge{}).Error; err != nil {
+		log.Printf("Failed to prune passkey challenges: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.PasswordResetToken{}).Error; err != nil {
+		log.Printf("Failed to prune password reset tokens: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.OIDCState{}).Error; err != nil {
+		log.Printf("Failed to prune OIDC states: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.OIDCLoginCode{}).Error; err != nil {
+		log.Printf("Failed to prune OIDC login codes: %v", err)
+	}
+	if err := db.Where("expires_at < ?", now).Delete(&models.Captcha{}).Error; err != nil {
+		log.Printf("Failed to prune captchas: %v", err)
+	}
+
+	// 已吊销或已过期的 API 令牌保留一段时间供审计
+	cutoff := now.Add(-apiTokenRetention)
+	if err := db.Where("revoked_at < ? OR expires_at < ?", cutoff, cutoff).Delete(&models.APIToken{}).Error; err != nil {
+		log.Printf("Failed to prune API tokens: %v", err)
+	}
+
+	if err := db.Where("created_at < ?", now.Add(-securityEventRetention)).Delete(&models.SecurityEvent{}).Error; err != nil {
+		log.Printf("Failed to prune security events: %v", err)
+	}
+
+	disableExpiredAccounts(db, now)
+
+	// 一天内没有新失败记录且未处于锁定状态的登录限制可以清除
+	if err := db.Where("updated_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-24*time.Hour), now).
+		Delete(&models.LoginThrottle{}).Error; err != nil {
+		log.Printf("Failed to prune login throttles: %v", err)
+	}
+}
+
+// disableExpiredAccounts 将已到期的账户标记为停用并结束其会话；
+// 登录和认证中间件会直接检查到期时间，这里只是让状态在列表中可见
+func disableExpiredAccounts(db *gorm.DB, now time.Time) {
+	var users []models.User
+	if err := db.Where("disabled_at IS NULL AND expires_at <= ?", now).Find(&users).Error; err != nil {
+		log.Printf("Failed to find expired accounts: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"disabled_at":     now,
+				"disabled_reason": "账户已过期",
+			}).Error; err != nil {
+				return err
+			}
+			event := models.SecurityEvent{
+				UserID:   &user.ID,
+				Username: user.Username,
+				Type:     models.SecurityEventAccountDisabled,
+				Detail:   "账户已过期",
+			}
+			if err := tx.Create(&event).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Session{}).
+				Where("user_id = ? AND revoked_at IS NULL", user.ID).
+				Update("revoked_at", now).Error
+		})
+		if err != nil {
+			log.Printf("Failed to disable expired account %s: %v", user.Username, err)
+			continue
+		}
+		log.Printf("Disabled expired account %s", user.Username)
+	}
+}
@@ -1,6 +1,9 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+	"log"
 	"os"
 	"path/filepath"
 	"backend/internal/config"
@@ -17,14 +20,44 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	// 打开数据库连接
-	db, err := gorm.Open(sqlite.Open(cfg.DatabasePath), &gorm.Config{})
+	// 打开数据库连接，事务使用支持提交后回调的连接
+	sqlDB, err := sql.Open(sqlite.DriverName, cfg.DatabasePath)
 	if err != nil {
 		return nil, err
 	}
+	db, err := gorm.Open(sqlite.New(sqlite.Config{Conn: connPool{sqlDB}}), &gorm.Config{})
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 
 	// 自动迁移数据库结构
-	if err := db.AutoMigrate(&models.User{}, &models.Option{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.Option{},
+		&models.RevokedToken{},
+		&models.SigningKey{},
+		&models.RefreshToken{},
+		&models.Session{},
+		&models.RecoveryCode{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnChallenge{},
+		&models.LoginThrottle{},
+		&models.PasswordHistory{},
+		&models.PasswordResetToken{},
+		&models.OIDCState{},
+		&models.UserIdentity{},
+		&models.OIDCLoginCode{},
+		&models.APIToken{},
+		&models.ImpersonationAudit{},
+		&models.IPRule{},
+		&models.Captcha{},
+		&models.SecurityEvent{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.UserRole{},
+		&models.Policy{},
+	); err != nil {
 		return nil, err
 	}
 
@@ -37,6 +70,15 @@ func InitDB(cfg *config.Config) (*gorm.DB, error) {
 		if err := initializeSystem(db); err != nil {
 			return nil, err
 		}
+	} else if err := initDefaultOptions(db); err != nil {
+		// 已初始化的系统补充新版本增加的配置项
+		return nil, err
+	} else if err := initRoles(db); err != nil {
+		// 旧版本升级时创建内置角色并迁移管理员
+		return nil, err
+	} else if err := requireDefaultPasswordChange(db); err != nil {
+		// 旧版本创建的管理员可能仍在使用默认密码
+		return nil, err
 	}
 
 	return db, nil
@@ -55,13 +97,71 @@ func createDefaultAdmin(db *gorm.DB) error {
 		return err
 	}
 
+	// 默认密码公开可知，首次登录后必须修改
 	admin := models.User{
-		Username: "admin",
-		Password: string(hashedPassword),
-		IsAdmin:  true,
+		Username:           "admin",
+		Password:           string(hashedPassword),
+		MustChangePassword: true,
 	}
 
-	return db.Create(&admin).Error
+	if err := db.Create(&admin).Error; err != nil {
+		return err
+	}
+	_, err = models.AssignRole(db, admin.ID, models.RoleAdmin, true)
+	return err
+}
+
+// requireDefaultPasswordChange 默认管理员仍使用公开的默认密码时，要求其登录后先修改密码
+func requireDefaultPasswordChange(db *gorm.DB) error {
+	var admin models.User
+	err := db.Where("username = ? AND source = ? AND must_change_password = ?", "admin", models.UserSourceLocal, false).
+		First(&admin).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte("admin")) != nil {
+		return nil
+	}
+
+	log.Printf("User admin still uses the default password, requiring a password change")
+	return db.Model(&admin).Update("must_change_password", true).Error
+}
+
+// initRoles 创建内置的 admin 角色；旧版本以 users.is_admin 标记管理员，迁移到 admin 角色后删除该列
+func initRoles(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		err := tx.Where("name = ?", models.RoleAdmin).First(&role).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			role = models.Role{Name: models.RoleAdmin, Description: "系统管理员，拥有全部权限", BuiltIn: true}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.RolePermission{RoleID: role.ID, Permission: models.PermissionAll}).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if !tx.Migrator().HasColumn(&models.User{}, "is_admin") {
+			return nil
+		}
+		var adminIDs []uint
+		if err := tx.Table("users").Where("is_admin = ?", true).Pluck("id", &adminIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range adminIDs {
+			if _, err := models.AssignRole(tx, id, models.RoleAdmin, true); err != nil {
+				return err
+			}
+		}
+		log.Printf("Migrated %d admin users to the %s role", len(adminIDs), models.RoleAdmin)
+		return tx.Migrator().DropColumn(&models.User{}, "is_admin")
+	})
 }
 
 func initDefaultOptions(db *gorm.DB) error {
@@ -82,6 +182,11 @@ func initDefaultOptions(db *gorm.DB) error {
 func initializeSystem(db *gorm.DB) error {
 	// 开始事务
 	return db.Transaction(func(tx *gorm.DB) error {
+		// 创建内置角色
+		if err := initRoles(tx); err != nil {
+			return err
+		}
+
 		// 创建默认管理员账户
 		if err := createDefaultAdmin(tx); err != nil {
 			return err
@@ -0,0 +1,69 @@
+package database_test
+
+import (
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func openDB(t *testing.T, cfg *config.Config) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func mustChangePassword(t *testing.T, db *gorm.DB) bool {
+	t.Helper()
+	var admin models.User
+	if err := db.Where("username = ?", "admin").First(&admin).Error; err != nil {
+		t.Fatalf("load admin: %v", err)
+	}
+	return admin.MustChangePassword
+}
+
+func TestUpgradeRequiresChangingDefaultAdminPassword(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	cfg := config.LoadConfig()
+
+	// 模拟旧版本创建的、没有强制修改标记的默认管理员
+	db := openDB(t, cfg)
+	if !mustChangePassword(t, db) {
+		t.Fatal("new default admin must change the password")
+	}
+	if err := db.Model(&models.User{}).Where("username = ?", "admin").Update("must_change_password", false).Error; err != nil {
+		t.Fatalf("clear flag: %v", err)
+	}
+
+	db = openDB(t, cfg)
+	if !mustChangePassword(t, db) {
+		t.Fatal("upgrade must flag the admin still using the default password")
+	}
+
+	// 已修改过密码的管理员不受影响
+	hash, err := bcrypt.GenerateFromPassword([]byte("Zx-98765!qwe"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	err = db.Model(&models.User{}).Where("username = ?", "admin").
+		Updates(map[string]interface{}{"password": string(hash), "must_change_password": false}).Error
+	if err != nil {
+		t.Fatalf("change password: %v", err)
+	}
+	db = openDB(t, cfg)
+	if mustChangePassword(t, db) {
+		t.Fatal("admin with a changed password must not be flagged")
+	}
+}
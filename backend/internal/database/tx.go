@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// connPool 包装连接池，开启的事务支持注册提交后执行的回调
+type connPool struct {
+	*sql.DB
+}
+
+func (p connPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &txConn{Tx: tx, db: p.DB}, nil
+}
+
+func (p connPool) GetDBConn() (*sql.DB, error) {
+	return p.DB, nil
+}
+
+// txConn 事务连接，提交成功后依次执行 AfterCommit 注册的回调，回滚时丢弃
+type txConn struct {
+	*sql.Tx
+	db *sql.DB
+
+	mu          sync.Mutex
+	afterCommit []func()
+}
+
+func (t *txConn) Commit() error {
+	if err := t.Tx.Commit(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	callbacks := t.afterCommit
+	t.afterCommit = nil
+	t.mu.Unlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+	return nil
+}
+
+func (t *txConn) GetDBConn() (*sql.DB, error) {
+	return t.db, nil
+}
+
+// AfterCommit 在 db 所在的事务提交后执行 fn，不在事务中时立即执行。
+// 用于清除缓存等操作：事务提交前其他连接仍会读到旧数据
+func AfterCommit(db *gorm.DB, fn func()) {
+	tx, ok := db.Statement.ConnPool.(*txConn)
+	if !ok {
+		fn()
+		return
+	}
+	tx.mu.Lock()
+	tx.afterCommit = append(tx.afterCommit, fn)
+	tx.mu.Unlock()
+}
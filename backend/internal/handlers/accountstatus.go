@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findManagedUser 按路径参数查找用户，admin 用户和当前管理员自己不能被停用，找不到或不允许时已写入响应
+func (h *Handler) findManagedUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return nil, false
+	}
+
+	value, _ := c.Get("user")
+	if user.Username == "admin" || user.ID == value.(*models.User).ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能停用admin用户或自己"})
+		return nil, false
+	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return nil, false
+	}
+	return &user, true
+}
+
+// DisableUser 停用账户而不删除数据，同时结束其所有会话；API 令牌保留，但在启用前无法使用
+func (h *Handler) DisableUser(c *gin.Context) {
+	user, ok := h.findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.DisableUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"disabled_at":     time.Now(),
+			"disabled_reason": strings.TrimSpace(req.Reason),
+		}).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "停用账户失败"})
+		return
+	}
+	h.recordAccountEvent(c, user, models.SecurityEventAccountDisabled, strings.TrimSpace(req.Reason))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "账户已停用",
+		"user":    toUserResponse(user),
+	})
+}
+
+// EnableUser 重新启用账户；已过的到期时间一并清除，否则账户会立即再次停用
+func (h *Handler) EnableUser(c *gin.Context) {
+	user, ok := h.findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"disabled_at":     nil,
+		"disabled_reason": "",
+	}
+	if user.ExpiresAt != nil && !user.ExpiresAt.After(time.Now()) {
+		updates["expires_at"] = nil
+	}
+	if err := h.db.Model(user).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用账户失败"})
+		return
+	}
+	h.recordAccountEvent(c, user, models.SecurityEventAccountEnabled, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "账户已启用",
+		"user":    toUserResponse(user),
+	})
+}
+
+// SetUserExpiry 设置或取消账户的到期时间，到期后账户自动停用，适用于外包等临时人员
+func (h *Handler) SetUserExpiry(c *gin.Context) {
+	user, ok := h.findManagedUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetUserExpiryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "到期时间必须晚于当前时间"})
+		return
+	}
+
+	if err := h.db.Model(user).Update("expires_at", req.ExpiresAt).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "设置到期时间失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "到期时间已更新",
+		"user":    toUserResponse(user),
+	})
+}
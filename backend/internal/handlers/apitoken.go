@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// apiTokenPrefixLength 保存并展示的令牌开头字符数，包含前缀
+const apiTokenPrefixLength = 12
+
+func toAPITokenResponse(token models.APIToken, username string) models.APITokenResponse {
+	return models.APITokenResponse{
+		ID:         token.ID,
+		UserID:     token.UserID,
+		Username:   username,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     token.ScopeList(),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		RevokedAt:  token.RevokedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// validateAPITokenScopes 校验并去重权限范围，管理类范围只能由管理员授予
+func validateAPITokenScopes(user *models.User, scopes []string) ([]string, string) {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, scope := range scopes {
+		valid := false
+		for _, s := range models.APITokenScopes {
+			if scope == s {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, "无效的权限范围: " + scope
+		}
+		if (scope == models.ScopeAdmin || scope == models.ScopeUserAdmin) && !user.IsAdmin() {
+			return nil, "只有管理员可以授予管理权限范围"
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result, ""
+}
+
+// createAPIToken 生成并保存 API 令牌，返回只能在此时获得的明文
+func createAPIToken(db *gorm.DB, userID uint, name string, scopes []string, expiresAt *time.Time) (string, *models.APIToken, error) {
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	plaintext := models.APITokenPrefix + secret
+
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plaintext[:apiTokenPrefixLength],
+		TokenHash: hashToken(plaintext),
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(&token).Error; err != nil {
+		return "", nil, err
+	}
+	return plaintext, &token, nil
+}
+
+// ListAPITokens 列出当前用户未吊销的 API 令牌
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var tokens []models.APIToken
+	if err := h.db.Where("user_id = ? AND revoked_at IS NULL", currentUser.ID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取令牌列表失败"})
+		return
+	}
+
+	response := make([]models.APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, toAPITokenResponse(token, ""))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateAPIToken 创建 API 令牌，明文只在创建时返回一次
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写令牌名称"})
+		return
+	}
+
+	scopes, message := validateAPITokenScopes(currentUser, req.Scopes)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期时间必须晚于当前时间"})
+		return
+	}
+
+	plaintext, token, err := createAPIToken(h.db, currentUser.ID, name, scopes, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     plaintext,
+		"api_token": toAPITokenResponse(*token, ""),
+		"message":   "令牌已创建，请立即保存，之后将无法再次查看",
+	})
+}
+
+// RevokeAPIToken 吊销当前用户的 API 令牌
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	user, _ := c.Get("user")
+	h.revokeAPIToken(c, user.(*models.User).ID)
+}
+
+// ListAllAPITokens 管理员审计所有用户的 API 令牌，可按 user_id 过滤，包含已吊销和已过期的令牌
+func (h *Handler) ListAllAPITokens(c *gin.Context) {
+	query := h.db.Order("created_at desc")
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		query = query.Where("user_id = ?", uint(id))
+	}
+
+	var tokens []models.APIToken
+	if err := query.Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取令牌列表失败"})
+		return
+	}
+
+	userIDs := make([]uint, 0, len(tokens))
+	for _, token := range tokens {
+		userIDs = append(userIDs, token.UserID)
+	}
+	var users []models.User
+	if err := h.db.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取令牌列表失败"})
+		return
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	response := make([]models.APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, toAPITokenResponse(token, usernames[token.UserID]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminRevokeAPIToken 管理员吊销任意用户的 API 令牌
+func (h *Handler) AdminRevokeAPIToken(c *gin.Context) {
+	h.revokeAPIToken(c, 0)
+}
+
+// revokeAPIToken 吊销路径参数指定的令牌，ownerID 不为 0 时只能吊销该用户的令牌
+func (h *Handler) revokeAPIToken(c *gin.Context, ownerID uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的令牌ID"})
+		return
+	}
+
+	query := h.db.Where("id = ? AND revoked_at IS NULL", uint(id))
+	if ownerID != 0 {
+		query = query.Where("user_id = ?", ownerID)
+	}
+	var token models.APIToken
+	if err := query.First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "令牌不存在"})
+		return
+	}
+
+	if err := h.db.Model(&token).Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "令牌已吊销"})
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+// createAPIToken 以 username 登录后创建 API 令牌，返回令牌明文和ID
+func (s *testServer) createAPIToken(t *testing.T, username string, scopes ...string) (string, uint) {
+	t.Helper()
+	session := []string{"Authorization", "Bearer " + s.login(t, username)}
+	rec := s.do(t, http.MethodPost, "/api/user/tokens", models.CreateAPITokenRequest{Name: "test", Scopes: scopes}, session...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create API token: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	response := decode(t, rec)
+	token, _ := response["token"].(string)
+	info, _ := response["api_token"].(map[string]interface{})
+	id, _ := info["id"].(float64)
+	if token == "" || id == 0 {
+		t.Fatalf("create API token returned %s", rec.Body.String())
+	}
+	return token, uint(id)
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	bobPath := fmt.Sprintf("/api/admin/users/%d", bob.ID)
+
+	read, _ := s.createAPIToken(t, "alice", models.ScopeRead)
+	write, _ := s.createAPIToken(t, "alice", models.ScopeWrite)
+	userAdmin, _ := s.createAPIToken(t, "alice", models.ScopeUserAdmin)
+	admin, _ := s.createAPIToken(t, "alice", models.ScopeAdmin)
+
+	tests := []struct {
+		name, token, method, path string
+		body                      interface{}
+		want                      int
+	}{
+		{"read own data", read, http.MethodGet, "/api/user", nil, http.StatusOK},
+		{"read cannot write", read, http.MethodDelete, "/api/user/tokens/999", nil, http.StatusForbidden},
+		{"read admin data", read, http.MethodGet, "/api/admin/roles", nil, http.StatusOK},
+		{"read cannot write admin data", read, http.MethodPost, bobPath + "/disable", nil, http.StatusForbidden},
+		{"write own data", write, http.MethodDelete, "/api/user/tokens/999", nil, http.StatusNotFound},
+		{"write cannot read admin data", write, http.MethodGet, "/api/admin/roles", nil, http.StatusForbidden},
+		{"user-admin manages users", userAdmin, http.MethodPost, bobPath + "/disable", nil, http.StatusOK},
+		{"user-admin limited to users", userAdmin, http.MethodGet, "/api/admin/roles", nil, http.StatusForbidden},
+		{"admin reads options", admin, http.MethodGet, "/api/admin/options", nil, http.StatusOK},
+		{"admin enables users", admin, http.MethodPost, bobPath + "/enable", nil, http.StatusOK},
+		// 账户安全相关的接口只能在登录会话中访问
+		{"no sessions", admin, http.MethodGet, "/api/user/sessions", nil, http.StatusForbidden},
+		{"no password change", admin, http.MethodPut, "/api/user/password", nil, http.StatusForbidden},
+		{"no impersonation", admin, http.MethodPost, bobPath + "/impersonate", nil, http.StatusForbidden},
+		{"no new tokens", admin, http.MethodPost, "/api/user/tokens", models.CreateAPITokenRequest{Name: "x", Scopes: []string{models.ScopeRead}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := s.do(t, tt.method, tt.path, tt.body, "Authorization", "Bearer "+tt.token); rec.Code != tt.want {
+			t.Errorf("%s: %s %s status %d, want %d, body %s", tt.name, tt.method, tt.path, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestAPITokenAdminScopesRequireAdmin(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	session := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	for _, scope := range []string{models.ScopeAdmin, models.ScopeUserAdmin, "superuser"} {
+		body := models.CreateAPITokenRequest{Name: "test", Scopes: []string{scope}}
+		if rec := s.do(t, http.MethodPost, "/api/user/tokens", body, session...); rec.Code != http.StatusBadRequest {
+			t.Errorf("scope %s: status %d, want 400", scope, rec.Code)
+		}
+	}
+	// 管理权限范围不能越过所有者自身的权限
+	token, _ := s.createAPIToken(t, "bob", models.ScopeRead)
+	if rec := s.do(t, http.MethodGet, "/api/admin/roles", nil, "Authorization", "Bearer "+token); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-admin read token on admin route: status %d", rec.Code)
+	}
+}
+
+func TestAPITokenRevocationAndExpiry(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	session := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	revoked, revokedID := s.createAPIToken(t, "bob", models.ScopeRead)
+	if rec := s.do(t, http.MethodDelete, fmt.Sprintf("/api/user/tokens/%d", revokedID), nil, session...); rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Authorization", "Bearer "+revoked); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("revoked token: status %d", rec.Code)
+	}
+
+	expired, expiredID := s.createAPIToken(t, "bob", models.ScopeRead)
+	if err := s.db.Model(&models.APIToken{}).Where("id = ?", expiredID).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("expire token: %v", err)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Authorization", "Bearer "+expired); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expired token: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"backend/internal/captcha"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captchaTTL 验证码的有效期
+const captchaTTL = 5 * time.Minute
+
+// GetCaptcha 生成新的验证码，图片以 data URL 返回，答案只保存在服务端
+func (h *Handler) GetCaptcha(c *gin.Context) {
+	challenge, err := captcha.New(models.GetOptionValue(h.db, models.OptionCaptchaType))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+
+	image, err := captcha.Render(challenge.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+
+	captchaID, err := generateTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+
+	record := models.Captcha{
+		CaptchaID:  captchaID,
+		AnswerHash: hashToken(challenge.Answer),
+		ExpiresAt:  time.Now().Add(captchaTTL),
+	}
+	if err := h.db.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成验证码失败"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"captcha_id": captchaID,
+		"image":      "data:image/png;base64," + base64.StdEncoding.EncodeToString(image),
+		"expires_in": int64(captchaTTL.Seconds()),
+	})
+}
+
+// verifyCaptcha 校验验证码答案；无论是否正确，验证码都只能使用一次
+func (h *Handler) verifyCaptcha(captchaID, answer string) bool {
+	if captchaID == "" || answer == "" {
+		return false
+	}
+
+	var record models.Captcha
+	if err := h.db.Where("captcha_id = ? AND expires_at > ?", captchaID, time.Now()).First(&record).Error; err != nil {
+		return false
+	}
+	// 只有成功删除的请求才能使用，避免并发请求重复使用同一个验证码
+	result := h.db.Delete(&record)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(record.AnswerHash), []byte(hashToken(captcha.Normalize(answer)))) == 1
+}
+
+// rejectInvalidCaptcha 验证码错误时返回 400，拦截时返回 true
+func (h *Handler) rejectInvalidCaptcha(c *gin.Context, captchaID, answer string) bool {
+	if h.verifyCaptcha(captchaID, answer) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误或已过期", "captcha_required": true})
+	return true
+}
+
+// loginCaptchaRequired 用户名或 IP 未过期的连续失败次数达到阈值后，登录需要验证码；username 为空时只看 IP
+func (h *Handler) loginCaptchaRequired(username, ip string) bool {
+	threshold := models.GetOptionInt(h.db, models.OptionLoginCaptchaThreshold, 3)
+	if threshold <= 0 {
+		return false
+	}
+
+	query := h.db.Where("kind = ? AND subject = ?", models.ThrottleKindIP, ip)
+	if username != "" {
+		query = query.Or("kind = ? AND subject = ?", models.ThrottleKindUsername, username)
+	}
+	var throttles []models.LoginThrottle
+	if err := query.Find(&throttles).Error; err != nil {
+		return true
+	}
+
+	policy := h.loadThrottlePolicy()
+	now := time.Now()
+	for i := range throttles {
+		if !policy.expired(&throttles[i], now) && throttles[i].Failures >= threshold {
+			return true
+		}
+	}
+	return false
+}
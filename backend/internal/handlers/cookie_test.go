@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"backend/internal/middleware"
+	"backend/internal/models"
+)
+
+// cookieLogin Cookie 模式下登录，返回响应设置的 Cookie
+func (s *testServer) cookieLogin(t *testing.T, username string) map[string]*http.Cookie {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: username, Password: testPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+// cookieHeader 拼接 Cookie 请求头
+func cookieHeader(cookies map[string]*http.Cookie, names ...string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		if cookie := cookies[name]; cookie != nil {
+			parts = append(parts, name+"="+cookie.Value)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestCookieModeSetsCookies(t *testing.T) {
+	s := newTestServer(t, map[string]string{"AUTH_MODE": "cookie"})
+	s.createUser(t, "bob")
+	cookies := s.cookieLogin(t, "bob")
+
+	for _, name := range []string{middleware.AccessTokenCookie, middleware.RefreshTokenCookie} {
+		if cookie := cookies[name]; cookie == nil || cookie.Value == "" || !cookie.HttpOnly {
+			t.Errorf("%s must be a non-empty HttpOnly cookie: %+v", name, cookie)
+		}
+	}
+	// 前端需要读取 CSRF 令牌
+	if cookie := cookies[middleware.CSRFCookie]; cookie == nil || cookie.Value == "" || cookie.HttpOnly {
+		t.Errorf("%s must be readable by scripts: %+v", middleware.CSRFCookie, cookie)
+	}
+}
+
+func TestCookieModeRequiresCSRFForWrites(t *testing.T) {
+	s := newTestServer(t, map[string]string{"AUTH_MODE": "cookie"})
+	s.createUser(t, "bob")
+	cookies := s.cookieLogin(t, "bob")
+	session := cookieHeader(cookies, middleware.AccessTokenCookie, middleware.CSRFCookie)
+	csrf := cookies[middleware.CSRFCookie].Value
+
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Cookie", session); rec.Code != http.StatusOK {
+		t.Fatalf("read without CSRF header: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodDelete, "/api/user/sessions", nil, "Cookie", session); rec.Code != http.StatusForbidden {
+		t.Fatalf("write without CSRF header: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodDelete, "/api/user/sessions", nil, "Cookie", session, middleware.CSRFHeader, csrf+"x"); rec.Code != http.StatusForbidden {
+		t.Fatalf("write with wrong CSRF header: status %d", rec.Code)
+	}
+	// 只有请求头没有 CSRF Cookie 时同样拒绝
+	accessOnly := cookieHeader(cookies, middleware.AccessTokenCookie)
+	if rec := s.do(t, http.MethodDelete, "/api/user/sessions", nil, "Cookie", accessOnly, middleware.CSRFHeader, csrf); rec.Code != http.StatusForbidden {
+		t.Fatalf("write without CSRF cookie: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodDelete, "/api/user/sessions", nil, "Cookie", session, middleware.CSRFHeader, csrf); rec.Code != http.StatusOK {
+		t.Fatalf("write with CSRF header: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCookieModeRefreshRequiresCSRF(t *testing.T) {
+	s := newTestServer(t, map[string]string{"AUTH_MODE": "cookie"})
+	s.createUser(t, "bob")
+	cookies := s.cookieLogin(t, "bob")
+	refresh := cookieHeader(cookies, middleware.RefreshTokenCookie, middleware.CSRFCookie)
+
+	if rec := s.do(t, http.MethodPost, "/api/token/refresh", nil, "Cookie", refresh); rec.Code != http.StatusForbidden {
+		t.Fatalf("refresh without CSRF header: status %d", rec.Code)
+	}
+	rec := s.do(t, http.MethodPost, "/api/token/refresh", nil, "Cookie", refresh, middleware.CSRFHeader, cookies[middleware.CSRFCookie].Value)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh with CSRF header: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	rotated := false
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == middleware.RefreshTokenCookie && cookie.Value != "" && cookie.Value != cookies[middleware.RefreshTokenCookie].Value {
+			rotated = true
+		}
+	}
+	if !rotated {
+		t.Fatal("refresh should set a rotated refresh token cookie")
+	}
+}
+
+func TestCookieModeLogoutClearsCookies(t *testing.T) {
+	s := newTestServer(t, map[string]string{"AUTH_MODE": "cookie"})
+	s.createUser(t, "bob")
+	cookies := s.cookieLogin(t, "bob")
+	session := cookieHeader(cookies, middleware.AccessTokenCookie, middleware.CSRFCookie)
+
+	rec := s.do(t, http.MethodPost, "/api/logout", nil, "Cookie", session, middleware.CSRFHeader, cookies[middleware.CSRFCookie].Value)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	cleared := map[string]bool{}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			cleared[cookie.Name] = true
+		}
+	}
+	for _, name := range []string{middleware.AccessTokenCookie, middleware.RefreshTokenCookie, middleware.CSRFCookie} {
+		if !cleared[name] {
+			t.Errorf("logout should clear %s", name)
+		}
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Cookie", session); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access cookie after logout: status %d", rec.Code)
+	}
+}
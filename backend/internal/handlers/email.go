@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/internal/mailer"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	purposeVerifyEmail  = "verify_email"
+	verifyEmailTokenTTL = 24 * time.Hour
+)
+
+var errInvalidVerifyToken = errors.New("invalid email verification token")
+
+// normalizeEmail 邮箱统一去除空白并转为小写后存储和比较
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// optionalEmail 空邮箱保存为 NULL，避免与唯一索引冲突
+func optionalEmail(email string) *string {
+	if email = normalizeEmail(email); email == "" {
+		return nil
+	}
+	return &email
+}
+
+// validEmail 检查邮箱格式，只接受不带显示名的纯地址
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// findUserByLogin 按用户名或邮箱查找用户
+func (h *Handler) findUserByLogin(login string, user *models.User) error {
+	return h.db.Where("username = ? OR email = ?", login, normalizeEmail(login)).First(user).Error
+}
+
+// signEmailVerificationToken 签发邮箱验证令牌，令牌绑定邮箱地址，邮箱变更后自动失效
+func (h *Handler) signEmailVerificationToken(user *models.User) (string, error) {
+	return h.keys.Sign(jwt.MapClaims{
+		"id":      user.ID,
+		"email":   *user.Email,
+		"purpose": purposeVerifyEmail,
+		"exp":     time.Now().Add(verifyEmailTokenTTL).Unix(),
+	})
+}
+
+// parseEmailVerificationToken 校验邮箱验证令牌，返回用户ID和邮箱
+func (h *Handler) parseEmailVerificationToken(tokenString string) (uint, string, error) {
+	claims, err := h.keys.Parse(tokenString)
+	if err != nil || claims["purpose"] != purposeVerifyEmail {
+		return 0, "", errInvalidVerifyToken
+	}
+	id, _ := claims["id"].(float64)
+	email, _ := claims["email"].(string)
+	if id == 0 || email == "" {
+		return 0, "", errInvalidVerifyToken
+	}
+	return uint(id), email, nil
+}
+
+// sendVerificationMail 向用户邮箱发送验证链接
+func (h *Handler) sendVerificationMail(user models.User) {
+	token, err := h.signEmailVerificationToken(&user)
+	if err != nil {
+		log.Printf("Failed to sign email verification token: %v", err)
+		return
+	}
+
+	link := strings.TrimRight(h.cfg.PublicURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	systemName := models.GetOptionValue(h.db, models.OptionSystemName)
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: systemName + " 邮箱验证",
+		Body: fmt.Sprintf("%s，您好：\n\n感谢您的注册。请在 %d 小时内打开以下链接验证邮箱，验证后即可登录：\n\n%s\n\n如果您没有注册过该账户，请忽略此邮件。\n",
+			user.Username, int(verifyEmailTokenTTL.Hours()), link),
+	}
+	if err := h.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send verification mail: %v", err)
+	}
+}
+
+// VerifyEmail 使用邮件中的链接验证邮箱
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	userID, email, err := h.parseEmailVerificationToken(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ? AND email = ?", userID, email).First(&user).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证链接无效或已过期"})
+		return
+	}
+
+	if user.EmailVerifiedAt == nil || user.PendingVerification {
+		updates := map[string]interface{}{
+			"email_verified_at":    time.Now(),
+			"pending_verification": false,
+		}
+		if err := h.db.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邮箱失败"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功"})
+}
@@ -3,25 +3,101 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
+	"backend/internal/auth"
 	"backend/internal/config"
+	"backend/internal/ipfilter"
+	"backend/internal/keyring"
+	"backend/internal/mailer"
+	"backend/internal/middleware"
 	"backend/internal/models"
+	"backend/internal/policy"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
 type Handler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db       *gorm.DB
+	cfg      *config.Config
+	keys     *keyring.Keyring
+	webAuthn *webauthn.WebAuthn
+	mailer   mailer.Mailer
+	ips      *ipfilter.Filter
+	policies *policy.Engine
+	routes   func() gin.RoutesInfo
+
+	authenticators auth.Chain
+	ldap           *auth.LDAP
+
+	oidcMu sync.Mutex
+	oidc   *oidcClient
 }
 
-func NewHandler(db *gorm.DB, cfg *config.Config) *Handler {
-	return &Handler{db: db, cfg: cfg}
+func NewHandler(db *gorm.DB, cfg *config.Config, keys *keyring.Keyring, ips *ipfilter.Filter, policies *policy.Engine) *Handler {
+	h := &Handler{db: db, cfg: cfg, keys: keys, mailer: mailer.New(cfg), ips: ips, policies: policies}
+
+	// 本地密码优先，本地不存在的用户再交给 LDAP
+	h.authenticators = auth.Chain{auth.NewLocal(db)}
+	if cfg.LDAPURL != "" {
+		h.ldap = auth.NewLDAP(db, auth.LDAPConfig{
+			URL:                cfg.LDAPURL,
+			StartTLS:           cfg.LDAPStartTLS,
+			InsecureSkipVerify: cfg.LDAPInsecureSkipVerify,
+			BindDN:             cfg.LDAPBindDN,
+			BindPassword:       cfg.LDAPBindPassword,
+			BaseDN:             cfg.LDAPBaseDN,
+			UserFilter:         cfg.LDAPUserFilter,
+			UsernameAttribute:  cfg.LDAPUsernameAttribute,
+			EmailAttribute:     cfg.LDAPEmailAttribute,
+			GroupAttribute:     cfg.LDAPGroupAttribute,
+			AdminGroup:         cfg.LDAPAdminGroup,
+			Timeout:            ldapTimeout,
+		})
+		h.authenticators = append(h.authenticators, h.ldap)
+	}
+
+	// 通行密钥配置无效时仅禁用该功能，不影响其他登录方式
+	systemName := models.GetOptionValue(db, models.OptionSystemName)
+	webAuthn, err := newWebAuthn(cfg.WebAuthnRPID, cfg.WebAuthnRPOrigins, systemName)
+	if err != nil {
+		log.Printf("Passkey login disabled: %v", err)
+	} else {
+		h.webAuthn = webAuthn
+	}
+
+	return h
+}
+
+func toUserResponse(user *models.User) models.UserResponse {
+	return models.UserResponse{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Email:              stringValue(user.Email),
+		EmailVerified:      user.EmailVerifiedAt != nil,
+		IsAdmin:            user.IsAdmin(),
+		Roles:              emptyIfNil(user.Roles),
+		Permissions:        emptyIfNil(user.Permissions),
+		Source:             user.Source,
+		AccountType:        user.AccountType,
+		MustChangePassword: user.MustChangePassword,
+		Disabled:           user.IsDisabled(time.Now()),
+		DisabledAt:         user.DisabledAt,
+		DisabledReason:     user.DisabledReason,
+		ExpiresAt:          user.ExpiresAt,
+		LastLoginAt:        user.LastLoginAt,
+		LastLoginIP:        user.LastLoginIP,
+		CreatedAt:          user.CreatedAt,
+		UpdatedAt:          user.UpdatedAt,
+	}
 }
 
 func (h *Handler) Login(c *gin.Context) {
@@ -31,40 +107,138 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := h.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+	// 支持使用邮箱登录，失败次数统一按用户名计算
+	subject := req.Username
+	var existing *models.User
+	var found models.User
+	if h.findUserByLogin(req.Username, &found) == nil {
+		subject = found.Username
+		existing = &found
+	}
+
+	// 用户名或IP连续失败过多时需等待退避或锁定结束
+	wait, err := h.loginRetryAfter(subject, c.ClientIP())
+	if err != nil {
+		rejectThrottleUnavailable(c)
+		return
+	}
+	if wait > 0 {
+		h.recordLoginEvent(c, existing, subject, models.LoginMethodPassword, "登录尝试过于频繁")
+		rejectThrottledLogin(c, wait)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
+	// 连续失败达到阈值后需要先通过验证码，验证码错误不计入失败次数
+	if h.loginCaptchaRequired(subject, c.ClientIP()) && h.rejectInvalidCaptcha(c, req.CaptchaID, req.CaptchaAnswer) {
+		h.recordLoginEvent(c, existing, subject, models.LoginMethodPassword, "验证码错误")
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":       user.ID,
-		"username": user.Username,
-		"is_admin": user.IsAdmin,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
-	})
+	// 依次尝试本地密码、LDAP 等认证方式
+	user, err := h.authenticators.Authenticate(c.Request.Context(), req.Username, req.Password)
+	if errors.Is(err, auth.ErrUnavailable) {
+		h.recordLoginEvent(c, existing, subject, models.LoginMethodPassword, "认证服务不可用")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "认证服务暂不可用，请稍后再试"})
+		return
+	}
+	if err != nil {
+		if err := h.recordLoginFailure(subject, c.ClientIP()); err != nil {
+			rejectThrottleUnavailable(c)
+			return
+		}
+		h.recordLoginEvent(c, existing, subject, models.LoginMethodPassword, "用户名或密码错误")
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":            "用户名或密码错误",
+			"captcha_required": h.loginCaptchaRequired(subject, c.ClientIP()),
+		})
+		return
+	}
+
+	method := models.LoginMethodPassword
+	if user.Source == models.UserSourceLDAP {
+		method = models.LoginMethodLDAP
+	}
+
+	h.finishFirstFactor(c, user, method, req.RememberMe)
+}
+
+// finishFirstFactor 密码或单点登录验证通过后检查邮箱验证、账户状态和来源 IP，
+// 启用两步验证时返回挑战令牌，否则完成登录
+func (h *Handler) finishFirstFactor(c *gin.Context, user *models.User, method string, rememberMe bool) {
+	if user.PendingVerification {
+		h.recordLoginEvent(c, user, user.Username, method, "邮箱尚未验证")
+		c.JSON(http.StatusForbidden, gin.H{"error": "邮箱尚未验证，请先完成邮箱验证", "verification_required": true})
+		return
+	}
+
+	// 身份验证通过后才提示账户已停用，避免泄露账户状态
+	if h.rejectLoginUser(c, user, method) {
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	// 启用两步验证的用户需先通过第二因素校验
+	if user.TOTPEnabled {
+		challenge, err := h.signChallengeToken(user, rememberMe)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	h.clearLoginFailures(user.Username)
+	h.completeLogin(c, user, method, rememberMe)
+}
+
+// rejectLoginUser 账户已停用或当前 IP 不允许该用户登录时拒绝并记录，拦截时返回 true
+func (h *Handler) rejectLoginUser(c *gin.Context, user *models.User, method string) bool {
+	if rejectDisabledAccount(c, user) {
+		h.recordLoginEvent(c, user, user.Username, method, "账户已停用")
+		return true
+	}
+	if middleware.RejectUserIP(c, h.ips, user) {
+		h.recordLoginEvent(c, user, user.Username, method, "当前 IP 不允许访问")
+		return true
+	}
+	return false
+}
+
+// rejectDisabledAccount 账户已停用或已到期时拒绝登录，拦截时返回 true
+func rejectDisabledAccount(c *gin.Context, user *models.User) bool {
+	if !user.IsDisabled(time.Now()) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "账户已停用", "account_disabled": true})
+	return true
+}
+
+// completeLogin 身份验证通过后创建会话并返回令牌，rememberMe 时使用较长的会话有效期；
+// method 为本次登录使用的方式，记录在登录历史中
+func (h *Handler) completeLogin(c *gin.Context, user *models.User, method string, rememberMe bool) {
+	// 两步验证和通行密钥登录也经过这里
+	if h.rejectLoginUser(c, user, method) {
+		return
+	}
+
+	pair, err := h.startSession(c, user, rememberMe)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": tokenString,
-		"user": models.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
-	})
+	response, err := h.tokenResponse(c, pair)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成令牌失败"})
+		return
+	}
+	h.recordLoginEvent(c, user, user.Username, method, "")
+	response["two_factor_setup_required"] = requiresTwoFactorSetup(h.db, user)
+	response["user"] = toUserResponse(user)
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *Handler) Register(c *gin.Context) {
@@ -86,6 +260,22 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
+	// 自助注册始终需要验证码
+	if h.rejectInvalidCaptcha(c, req.CaptchaID, req.CaptchaAnswer) {
+		return
+	}
+
+	requireVerification := models.GetOptionValue(h.db, models.OptionRequireEmailVerification) == "true"
+	if requireVerification && normalizeEmail(req.Email) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写邮箱"})
+		return
+	}
+
+	if err := h.validatePassword(&models.User{Username: req.Username}, req.Password); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
@@ -93,31 +283,57 @@ func (h *Handler) Register(c *gin.Context) {
 	}
 
 	user := models.User{
-		Username: req.Username,
-		Password: string(hashedPassword),
-		IsAdmin:  false,
+		Username:            req.Username,
+		Password:            string(hashedPassword),
+		Email:               optionalEmail(req.Email),
+		PendingVerification: requireVerification,
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名或邮箱已存在"})
 		return
 	}
 
+	// 填写了邮箱就发送验证邮件，开启强制验证时验证前不能登录
+	if user.Email != nil {
+		go h.sendVerificationMail(user)
+	}
+
+	message := "注册成功"
+	if requireVerification {
+		message = "注册成功，请查收验证邮件完成验证后登录"
+	}
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "注册成功",
-		"user": models.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		"message":               message,
+		"verification_required": requireVerification,
+		"user":                  toUserResponse(&user),
 	})
 }
 
 func (h *Handler) GetUserInfo(c *gin.Context) {
 	user, _ := c.Get("user")
-	c.JSON(http.StatusOK, user)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, user.(*models.User).ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if err := models.LoadUserRoles(h.db, &dbUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	response := toUserResponse(&dbUser)
+	if value, ok := c.Get("impersonator"); ok {
+		impersonator := value.(*models.User)
+		session, _ := c.Get("session")
+		response.Impersonator = &models.ImpersonatorInfo{
+			ID:        impersonator.ID,
+			Username:  impersonator.Username,
+			ExpiresAt: session.(*models.Session).ExpiresAt,
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *Handler) UpdatePassword(c *gin.Context) {
@@ -136,26 +352,63 @@ func (h *Handler) UpdatePassword(c *gin.Context) {
 		return
 	}
 
+	if dbUser.Source != models.UserSourceLocal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该账户由外部身份源管理，请在对应系统中修改密码"})
+		return
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(req.OldPassword)); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "原密码错误"})
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+	if err := h.validatePassword(&dbUser, req.NewPassword); err != nil {
+		respondPasswordError(c, err)
 		return
 	}
 
-	if err := h.db.Model(&dbUser).Update("password", string(hashedPassword)).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return h.setPassword(tx, &dbUser, req.NewPassword, false)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新密码失败"})
 		return
 	}
+	h.recordAccountEvent(c, &dbUser, models.SecurityEventPasswordChanged, "")
 
 	c.JSON(http.StatusOK, gin.H{"message": "密码更新成功"})
 }
 
 func (h *Handler) Logout(c *gin.Context) {
+	value, _ := c.Get("claims")
+	claims := value.(jwt.MapClaims)
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的认证信息"})
+		return
+	}
+
+	// 记录令牌 jti，使其在过期前无法继续使用
+	revoked := models.RevokedToken{
+		JTI:       claims["jti"].(string),
+		ExpiresAt: exp.Time,
+	}
+	if err := h.db.Where("jti = ?", revoked.JTI).FirstOrCreate(&revoked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败"})
+		return
+	}
+
+	// 同时结束本次会话及其刷新令牌
+	if err := revokeSessions(h.db, "session_id = ?", currentSessionID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败"})
+		return
+	}
+
+	if h.cfg.AuthMode == config.AuthModeCookie {
+		middleware.ClearAuthCookies(c, h.cfg)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
 }
 
@@ -182,25 +435,38 @@ func (h *Handler) GetSysInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"allowRegistration": regOption.OptionValue == "true",
 		"systemName":       sysName,
+		"oidcEnabled":      h.oidcEnabled(),
+		// 按请求来源 IP 判断，按用户名判断的结果在登录失败的响应中返回
+		"loginCaptchaRequired":    h.loginCaptchaRequired("", c.ClientIP()),
+		"registerCaptchaRequired": true,
 	})
 }
 
+// ListUsers 默认只列出用户，type=service 时列出服务账户
 func (h *Handler) ListUsers(c *gin.Context) {
+	accountType := models.AccountTypeUser
+	if c.Query("type") == models.AccountTypeService {
+		accountType = models.AccountTypeService
+	}
+
 	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
+	if err := h.db.Where("account_type = ?", accountType).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
+		return
+	}
+
+	pointers := make([]*models.User, len(users))
+	for i := range users {
+		pointers[i] = &users[i]
+	}
+	if err := models.LoadUserRoles(h.db, pointers...); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
 		return
 	}
 
 	var response []models.UserResponse
 	for _, user := range users {
-		response = append(response, models.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		})
+		response = append(response, toUserResponse(&user))
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -213,6 +479,11 @@ func (h *Handler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if err := h.validatePassword(&models.User{Username: req.Username}, req.Password); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
@@ -222,23 +493,22 @@ func (h *Handler) CreateUser(c *gin.Context) {
 	user := models.User{
 		Username: req.Username,
 		Password: string(hashedPassword),
-		IsAdmin:  false,
+		Email:    optionalEmail(req.Email),
+	}
+	// 管理员填写的邮箱视为已验证
+	if user.Email != nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名或邮箱已存在"})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "用户创建成功",
-		"user": models.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		"user":    toUserResponse(&user),
 	})
 }
 
@@ -260,10 +530,14 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改admin用户"})
 		return
 	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
 
+	// 角色通过 PUT /api/admin/users/:id/roles 修改
 	var req struct {
-		Username string `json:"username"`
-		IsAdmin  bool   `json:"is_admin"`
+		Username string  `json:"username"`
+		Email    *string `json:"email"` // 不传时保持不变，传空字符串时清除
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
@@ -272,7 +546,22 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 	updates := map[string]interface{}{
 		"username": req.Username,
-		"is_admin": req.IsAdmin,
+	}
+	if req.Email != nil {
+		email := optionalEmail(*req.Email)
+		if email != nil && !validEmail(*email) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱格式不正确"})
+			return
+		}
+		if stringValue(email) != stringValue(user.Email) {
+			// 管理员修改的邮箱视为已验证
+			updates["email"] = email
+			updates["email_verified_at"] = nil
+			if email != nil {
+				updates["email_verified_at"] = time.Now()
+			}
+			updates["pending_verification"] = false
+		}
 	}
 
 	if err := h.db.Model(&user).Updates(updates).Error; err != nil {
@@ -282,13 +571,7 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "用户信息更新成功",
-		"user": models.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			IsAdmin:   user.IsAdmin,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		},
+		"user":    toUserResponse(&user),
 	})
 }
 
@@ -310,6 +593,9 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除admin用户"})
 		return
 	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
 
 	if err := h.db.Delete(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除用户失败"})
@@ -333,22 +619,68 @@ func (h *Handler) ResetUserPassword(c *gin.Context) {
 		return
 	}
 
-	// 设置默认的重置密码
-	defaultPassword := "123456"
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultPassword), bcrypt.DefaultCost)
+	if user.Source != models.UserSourceLocal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该用户由外部身份源管理，不能重置密码"})
+		return
+	}
+	if user.IsServiceAccount() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "服务账户没有密码，请轮换其凭据"})
+		return
+	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
+
+	var req models.ResetUserPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 生成一次性重置链接，由用户自行设置新密码
+	if req.Mode == "link" {
+		var token string
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			token, err = createPasswordResetToken(tx, user.ID, adminResetLinkTTL)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成重置链接失败"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "重置链接已生成",
+			"reset_link": h.passwordResetLink(token),
+			"expires_at": time.Now().Add(adminResetLinkTTL),
+		})
+		return
+	}
+
+	// 生成符合当前密码策略的随机临时密码，用户登录后必须修改
+	temporaryPassword, err := h.passwordPolicy().Generate(temporaryPasswordLength, user.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		log.Printf("Failed to generate temporary password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成临时密码失败"})
 		return
 	}
 
-	if err := h.db.Model(&user).Update("password", string(hashedPassword)).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.setPassword(tx, &user, temporaryPassword, true); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
 		return
 	}
+	h.recordAccountEvent(c, &user, models.SecurityEventPasswordReset, "管理员重置为临时密码")
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "密码重置成功",
-		"password": defaultPassword,
+		"message":  "密码重置成功",
+		"password": temporaryPassword,
 	})
 }
 
@@ -379,13 +711,21 @@ func (h *Handler) UpdateOptions(c *gin.Context) {
 		return
 	}
 
+	for _, update := range req.Options {
+		if err := models.ValidateOptionValue(update.OptionName, update.OptionValue); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 开始事务
+	var unknown string
 	err := h.db.Transaction(func(tx *gorm.DB) error {
 		for _, update := range req.Options {
 			var option models.Option
 			if err := tx.Where("option_name = ?", update.OptionName).First(&option).Error; err != nil {
 				if err == gorm.ErrRecordNotFound {
-					return fmt.Errorf("配置项 %s 不存在", update.OptionName)
+					unknown = update.OptionName
 				}
 				return err
 			}
@@ -397,10 +737,14 @@ func (h *Handler) UpdateOptions(c *gin.Context) {
 		return nil
 	})
 
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("配置项 %s 不存在", unknown)})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新配置失败: %v", err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "配置更新成功"})
-} 
\ No newline at end of file
+} 
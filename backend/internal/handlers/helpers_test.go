@@ -0,0 +1,160 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/keyring"
+	"backend/internal/models"
+	"backend/internal/router"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// testPassword 满足默认密码策略的测试密码
+const testPassword = "Zx-98765!qwe"
+
+// testServer 使用临时数据库的完整路由
+type testServer struct {
+	router *gin.Engine
+	db     *gorm.DB
+}
+
+// newTestServer 按 env 设置环境变量后初始化配置、数据库和路由
+func newTestServer(t *testing.T, env map[string]string) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	t.Setenv("DATA_PATH", t.TempDir())
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg := config.LoadConfig()
+
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	keys, err := keyring.New(db, cfg)
+	if err != nil {
+		t.Fatalf("keyring.New: %v", err)
+	}
+	return &testServer{router: router.SetupRouter(db, cfg, keys), db: db}
+}
+
+// do 发送请求，body 为 []byte 时原样发送，其他非 nil 值编码为 JSON
+func (s *testServer) do(t *testing.T, method, target string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.doFrom(t, "", method, target, body, headers...)
+}
+
+// doFrom 与 do 相同，remoteAddr 不为空时作为请求的直接来源地址（ip:port）
+func (s *testServer) doFrom(t *testing.T, remoteAddr, method, target string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	data, raw := body.([]byte)
+	if !raw && body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, target, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decode 解析 JSON 响应
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return result
+}
+
+// createUser 直接在数据库中创建密码为 testPassword 的普通用户
+func (s *testServer) createUser(t *testing.T, username string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := models.User{Username: username, Password: string(hash)}
+	if err := s.db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+// createAdmin 创建拥有 admin 角色的用户
+func (s *testServer) createAdmin(t *testing.T, username string) *models.User {
+	t.Helper()
+	user := s.createUser(t, username)
+	if _, err := models.AssignRole(s.db, user.ID, models.RoleAdmin, true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	return user
+}
+
+// createOperator 创建只拥有给定权限的用户，角色名与用户名相同
+func (s *testServer) createOperator(t *testing.T, username string, permissions ...string) *models.User {
+	t.Helper()
+	user := s.createUser(t, username)
+	role := models.Role{Name: username}
+	if err := s.db.Create(&role).Error; err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	for _, permission := range permissions {
+		if err := s.db.Create(&models.RolePermission{RoleID: role.ID, Permission: permission}).Error; err != nil {
+			t.Fatalf("grant %s: %v", permission, err)
+		}
+	}
+	if _, err := models.AssignRole(s.db, user.ID, role.Name, true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	return user
+}
+
+// login 使用密码登录，返回访问令牌
+func (s *testServer) login(t *testing.T, username string) string {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: username, Password: testPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	token, _ := decode(t, rec)["token"].(string)
+	if token == "" {
+		t.Fatalf("login returned no token: %s", rec.Body.String())
+	}
+	return token
+}
+
+// noRedirectClient 不跟随跳转，便于逐步检查授权流程
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// impersonationTTL 模拟登录令牌的有效期，到期后不能刷新
+	impersonationTTL = 30 * time.Minute
+	// impersonationAuditLimit 审计日志单次返回的最大条数
+	impersonationAuditLimit = 500
+)
+
+// Impersonate 管理员以指定用户的身份登录，用于排查问题；
+// 签发的令牌不带刷新令牌，会话中记录发起模拟的管理员和原因
+func (h *Handler) Impersonate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	value, _ := c.Get("user")
+	admin := value.(*models.User)
+
+	var req models.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写模拟登录的原因"})
+		return
+	}
+
+	var target models.User
+	if err := h.db.First(&target, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if err := models.LoadUserRoles(h.db, &target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		return
+	}
+	if target.ID == admin.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能模拟自己"})
+		return
+	}
+	// 模拟拥有管理权限的用户会让操作难以区分，服务账户没有界面可看
+	if target.IsAdmin() || target.IsServiceAccount() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能模拟管理员或服务账户"})
+		return
+	}
+	if target.IsDisabled(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能模拟已停用的账户"})
+		return
+	}
+
+	sessionID, err := generateTokenID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		return
+	}
+
+	now := time.Now()
+	session := models.Session{
+		SessionID:           sessionID,
+		UserID:              target.ID,
+		IP:                  c.ClientIP(),
+		UserAgent:           c.Request.UserAgent(),
+		LastSeenAt:          now,
+		ExpiresAt:           now.Add(impersonationTTL),
+		ImpersonatorID:      &admin.ID,
+		ImpersonationReason: strings.TrimSpace(req.Reason),
+	}
+	if err := h.db.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		return
+	}
+
+	claims, err := h.accessTokenClaims(&target, sessionID, session.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		return
+	}
+	// RFC 8693 的 act 声明标识实际操作者
+	claims["act"] = map[string]interface{}{
+		"sub":      strconv.FormatUint(uint64(admin.ID), 10),
+		"username": admin.Username,
+	}
+	token, err := h.keys.Sign(claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模拟登录失败"})
+		return
+	}
+
+	log.Printf("Admin %s (id %d) started impersonating %s (id %d): %s", admin.Username, admin.ID, target.Username, target.ID, session.ImpersonationReason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      token,
+		"expires_in": int64(impersonationTTL.Seconds()),
+		"user":       toUserResponse(&target),
+	})
+}
+
+// ListImpersonationAudits 查看模拟登录期间的请求记录，可按 user_id 和 impersonator_id 过滤
+func (h *Handler) ListImpersonationAudits(c *gin.Context) {
+	query := h.db.Order("id desc").Limit(impersonationAuditLimit)
+	for _, field := range []string{"user_id", "impersonator_id"} {
+		if value := c.Query(field); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+				return
+			}
+			query = query.Where(field+" = ?", uint(id))
+		}
+	}
+
+	var audits []models.ImpersonationAudit
+	if err := query.Find(&audits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取模拟登录记录失败"})
+		return
+	}
+
+	userIDs := []uint{}
+	for _, audit := range audits {
+		userIDs = append(userIDs, audit.UserID, audit.ImpersonatorID)
+	}
+	var users []models.User
+	if err := h.db.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取模拟登录记录失败"})
+		return
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	response := make([]models.ImpersonationAuditResponse, 0, len(audits))
+	for _, audit := range audits {
+		response = append(response, models.ImpersonationAuditResponse{
+			ID:                   audit.ID,
+			SessionID:            audit.SessionID,
+			ImpersonatorID:       audit.ImpersonatorID,
+			ImpersonatorUsername: usernames[audit.ImpersonatorID],
+			UserID:               audit.UserID,
+			Username:             usernames[audit.UserID],
+			Method:               audit.Method,
+			Path:                 audit.Path,
+			Status:               audit.Status,
+			IP:                   audit.IP,
+			CreatedAt:            audit.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+// impersonate 以 headers 中的身份模拟登录 targetID
+func (s *testServer) impersonate(t *testing.T, targetID uint, reason string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.do(t, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/impersonate", targetID), models.ImpersonateRequest{Reason: reason}, headers...)
+}
+
+// startImpersonation 模拟登录并返回模拟会话的访问令牌
+func (s *testServer) startImpersonation(t *testing.T, targetID uint, headers ...string) []string {
+	t.Helper()
+	rec := s.impersonate(t, targetID, "support ticket", headers...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("impersonate: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	token, _ := decode(t, rec)["token"].(string)
+	if token == "" {
+		t.Fatalf("impersonate returned no token: %s", rec.Body.String())
+	}
+	return []string{"Authorization", "Bearer " + token}
+}
+
+func TestImpersonateTargetRestrictions(t *testing.T) {
+	s := newTestServer(t, nil)
+	alice := s.createAdmin(t, "alice")
+	carol := s.createAdmin(t, "carol")
+	bob := s.createUser(t, "bob")
+	dave := s.createUser(t, "dave")
+	s.createOperator(t, "erin", models.PermissionUsersRead)
+	if err := s.db.Model(dave).Update("disabled_at", time.Now()).Error; err != nil {
+		t.Fatalf("disable dave: %v", err)
+	}
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+	operator := []string{"Authorization", "Bearer " + s.login(t, "erin")}
+
+	tests := []struct {
+		name    string
+		target  uint
+		reason  string
+		headers []string
+		want    int
+	}{
+		{"reason required", bob.ID, " ", admin, http.StatusBadRequest},
+		{"self", alice.ID, "test", admin, http.StatusBadRequest},
+		{"unknown user", 9999, "test", admin, http.StatusNotFound},
+		{"admin target", carol.ID, "test", admin, http.StatusForbidden},
+		{"disabled target", dave.ID, "test", admin, http.StatusForbidden},
+		{"missing permission", bob.ID, "test", operator, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := s.impersonate(t, tt.target, tt.reason, tt.headers...); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d, body %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestImpersonationSessionIsRestrictedAndAudited(t *testing.T) {
+	s := newTestServer(t, nil)
+	alice := s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+	session := s.startImpersonation(t, bob.ID, admin...)
+
+	rec := s.do(t, http.MethodGet, "/api/user", nil, session...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("user info: status %d", rec.Code)
+	}
+	var info models.UserResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("decode user info: %v", err)
+	}
+	if info.Username != "bob" || info.Impersonator == nil || info.Impersonator.ID != alice.ID {
+		t.Fatalf("user info should show bob impersonated by alice: %s", rec.Body.String())
+	}
+
+	// 账户凭据和安全设置只能查看
+	blocked := []struct{ method, path string }{
+		{http.MethodPut, "/api/user/password"},
+		{http.MethodDelete, "/api/user/sessions"},
+		{http.MethodPost, "/api/user/2fa/setup"},
+		{http.MethodPost, "/api/user/tokens"},
+	}
+	for _, tt := range blocked {
+		if rec := s.do(t, tt.method, tt.path, nil, session...); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s during impersonation: status %d", tt.method, tt.path, rec.Code)
+		}
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user/sessions", nil, session...); rec.Code != http.StatusOK {
+		t.Errorf("list sessions during impersonation: status %d", rec.Code)
+	}
+
+	var audits []models.ImpersonationAuditResponse
+	rec = s.do(t, http.MethodGet, fmt.Sprintf("/api/admin/impersonations?impersonator_id=%d", alice.ID), nil, admin...)
+	if err := json.Unmarshal(rec.Body.Bytes(), &audits); err != nil {
+		t.Fatalf("decode audits %q: %v", rec.Body.String(), err)
+	}
+	if len(audits) != len(blocked)+2 {
+		t.Fatalf("got %d audit records, want %d", len(audits), len(blocked)+2)
+	}
+	for _, audit := range audits {
+		if audit.UserID != bob.ID || audit.ImpersonatorUsername != "alice" {
+			t.Errorf("unexpected audit record %+v", audit)
+		}
+	}
+}
+
+func TestImpersonationEndsWhenImpersonatorLosesPermission(t *testing.T) {
+	s := newTestServer(t, nil)
+	alice := s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	session := s.startImpersonation(t, bob.ID, "Authorization", "Bearer "+s.login(t, "alice"))
+
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, session...); rec.Code != http.StatusOK {
+		t.Fatalf("before: status %d", rec.Code)
+	}
+	if err := s.db.Where("user_id = ?", alice.ID).Delete(&models.UserRole{}).Error; err != nil {
+		t.Fatalf("remove roles: %v", err)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, session...); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("after impersonator lost permission: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/ipfilter"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func toIPRuleResponse(rule models.IPRule, username string) models.IPRuleResponse {
+	return models.IPRuleResponse{
+		ID:          rule.ID,
+		Action:      rule.Action,
+		CIDR:        rule.CIDR,
+		Scope:       rule.Scope,
+		UserID:      rule.UserID,
+		Username:    username,
+		Description: rule.Description,
+		CreatedAt:   rule.CreatedAt,
+	}
+}
+
+// ListIPRules 列出所有 IP 访问规则，可按 scope 过滤
+func (h *Handler) ListIPRules(c *gin.Context) {
+	query := h.db.Order("scope, id")
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var rules []models.IPRule
+	if err := query.Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问规则失败"})
+		return
+	}
+
+	userIDs := []uint{}
+	for _, rule := range rules {
+		if rule.UserID != nil {
+			userIDs = append(userIDs, *rule.UserID)
+		}
+	}
+	var users []models.User
+	if err := h.db.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问规则失败"})
+		return
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	response := make([]models.IPRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		username := ""
+		if rule.UserID != nil {
+			username = usernames[*rule.UserID]
+		}
+		response = append(response, toIPRuleResponse(rule, username))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateIPRule 添加 IP 访问规则
+func (h *Handler) CreateIPRule(c *gin.Context) {
+	var req models.CreateIPRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	if req.Action != models.IPRuleAllow && req.Action != models.IPRuleDeny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则动作"})
+		return
+	}
+
+	validScope := false
+	for _, scope := range models.IPRuleScopes {
+		if req.Scope == scope {
+			validScope = true
+			break
+		}
+	}
+	if !validScope {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的作用范围"})
+		return
+	}
+
+	prefix, err := ipfilter.ParsePrefix(strings.TrimSpace(req.CIDR))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 IP 或 CIDR"})
+		return
+	}
+
+	rule := models.IPRule{
+		Action:      req.Action,
+		CIDR:        prefix.String(),
+		Scope:       req.Scope,
+		Description: strings.TrimSpace(req.Description),
+	}
+	username := ""
+	if req.Scope == models.IPRuleScopeUser {
+		if req.UserID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请指定用户"})
+			return
+		}
+		var user models.User
+		if err := h.db.First(&user, *req.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		rule.UserID = &user.ID
+		username = user.Username
+	}
+
+	if !h.ipRulesKeepAdminAccess(c, func(rules []models.IPRule) []models.IPRule {
+		return append(rules, rule)
+	}) {
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加访问规则失败"})
+		return
+	}
+	h.ips.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "访问规则已添加",
+		"rule":    toIPRuleResponse(rule, username),
+	})
+}
+
+// DeleteIPRule 删除 IP 访问规则
+func (h *Handler) DeleteIPRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	var rule models.IPRule
+	if err := h.db.First(&rule, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "访问规则不存在"})
+		return
+	}
+
+	if !h.ipRulesKeepAdminAccess(c, func(rules []models.IPRule) []models.IPRule {
+		remaining := rules[:0]
+		for _, r := range rules {
+			if r.ID != rule.ID {
+				remaining = append(remaining, r)
+			}
+		}
+		return remaining
+	}) {
+		return
+	}
+
+	if err := h.db.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除访问规则失败"})
+		return
+	}
+	h.ips.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "访问规则已删除"})
+}
+
+// ipRulesKeepAdminAccess 检查修改后的规则是否仍允许当前管理员从当前 IP 访问管理接口，
+// 避免管理员把自己锁在外面；不允许时已写入响应
+func (h *Handler) ipRulesKeepAdminAccess(c *gin.Context, apply func([]models.IPRule) []models.IPRule) bool {
+	var rules []models.IPRule
+	if err := h.db.Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问规则失败"})
+		return false
+	}
+	rules = apply(rules)
+
+	value, _ := c.Get("user")
+	admin := value.(*models.User)
+	ip := c.ClientIP()
+	for _, scope := range []string{models.IPRuleScopeGlobal, models.IPRuleScopeAdmin, models.IPRuleScopeUser} {
+		if err := ipfilter.Evaluate(rules, ip, scope, admin.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "修改后当前 IP（" + ip + "）将无法访问管理接口"})
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"backend/internal/models"
+)
+
+// createIPRule 通过管理接口添加访问规则
+func createIPRule(t *testing.T, s *testServer, token string, req models.CreateIPRuleRequest) {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/admin/ip-rules", req, "Authorization", "Bearer "+token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create ip rule: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIPRulesFilterRequests(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	createIPRule(t, s, s.login(t, "alice"), models.CreateIPRuleRequest{
+		Action: models.IPRuleDeny, CIDR: "203.0.113.0/24", Scope: models.IPRuleScopePublic,
+	})
+
+	login := models.LoginRequest{Username: "alice", Password: testPassword}
+	if rec := s.doFrom(t, "203.0.113.9:4000", http.MethodPost, "/api/login", login); rec.Code != http.StatusForbidden {
+		t.Fatalf("denied address: status %d", rec.Code)
+	}
+	if rec := s.doFrom(t, "198.51.100.1:4000", http.MethodPost, "/api/login", login); rec.Code != http.StatusOK {
+		t.Fatalf("other address: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestIPRulesTrustForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	s := newTestServer(t, nil) // 默认只信任本机代理
+	s.createAdmin(t, "alice")
+	createIPRule(t, s, s.login(t, "alice"), models.CreateIPRuleRequest{
+		Action: models.IPRuleDeny, CIDR: "203.0.113.0/24", Scope: models.IPRuleScopePublic,
+	})
+
+	login := models.LoginRequest{Username: "alice", Password: testPassword}
+	forwarded := []string{"X-Forwarded-For", "203.0.113.9"}
+	if rec := s.doFrom(t, "127.0.0.1:4000", http.MethodPost, "/api/login", login, forwarded...); rec.Code != http.StatusForbidden {
+		t.Fatalf("denied address behind trusted proxy: status %d", rec.Code)
+	}
+	// 不受信任的来源不能伪造 X-Forwarded-For 绕过或触发规则
+	if rec := s.doFrom(t, "198.51.100.1:4000", http.MethodPost, "/api/login", login, forwarded...); rec.Code != http.StatusOK {
+		t.Fatalf("forwarded header from untrusted peer: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.doFrom(t, "203.0.113.9:4000", http.MethodPost, "/api/login", login, "X-Forwarded-For", "198.51.100.1"); rec.Code != http.StatusForbidden {
+		t.Fatalf("spoofed forwarded header: status %d", rec.Code)
+	}
+}
+
+func TestIPRulesIgnoreForwardedForWithoutTrustedProxies(t *testing.T) {
+	s := newTestServer(t, map[string]string{"TRUSTED_PROXIES": ""})
+	s.createAdmin(t, "alice")
+	createIPRule(t, s, s.login(t, "alice"), models.CreateIPRuleRequest{
+		Action: models.IPRuleDeny, CIDR: "203.0.113.0/24", Scope: models.IPRuleScopePublic,
+	})
+
+	login := models.LoginRequest{Username: "alice", Password: testPassword}
+	if rec := s.doFrom(t, "127.0.0.1:4000", http.MethodPost, "/api/login", login, "X-Forwarded-For", "203.0.113.9"); rec.Code != http.StatusOK {
+		t.Fatalf("forwarded header without trusted proxies: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestUserIPRulesRestrictLogin(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	s.createUser(t, "carol")
+	createIPRule(t, s, s.login(t, "alice"), models.CreateIPRuleRequest{
+		Action: models.IPRuleAllow, CIDR: "10.0.0.0/8", Scope: models.IPRuleScopeUser, UserID: &bob.ID,
+	})
+
+	for _, tt := range []struct {
+		username, addr string
+		want           int
+	}{
+		{"bob", "10.1.2.3:4000", http.StatusOK},
+		{"bob", "198.51.100.1:4000", http.StatusForbidden},
+		{"carol", "198.51.100.1:4000", http.StatusOK},
+	} {
+		rec := s.doFrom(t, tt.addr, http.MethodPost, "/api/login", models.LoginRequest{Username: tt.username, Password: testPassword})
+		if rec.Code != tt.want {
+			t.Errorf("%s from %s: status %d, want %d", tt.username, tt.addr, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestIPRuleCannotLockOutCurrentAdmin(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+
+	// httptest 请求的来源地址为 192.0.2.1
+	rec := s.do(t, http.MethodPost, "/api/admin/ip-rules", models.CreateIPRuleRequest{
+		Action: models.IPRuleAllow, CIDR: "10.0.0.0/8", Scope: models.IPRuleScopeAdmin,
+	}, "Authorization", "Bearer "+s.login(t, "alice"))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("allowlist excluding the current address: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
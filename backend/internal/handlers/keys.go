@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"backend/internal/keyring"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS 发布验证访问令牌所需的公钥，供其他服务校验本系统签发的令牌
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// ListSigningKeys 管理员查看签名密钥
+func (h *Handler) ListSigningKeys(c *gin.Context) {
+	active := h.keys.Active()
+	keys := h.keys.Keys()
+	response := make([]models.SigningKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, models.SigningKeyResponse{
+			KID:       key.ID,
+			Algorithm: key.Algorithm,
+			Active:    key == active,
+			CreatedAt: key.CreatedAt,
+			RetiredAt: key.RetiredAt,
+			ExpiresAt: h.keys.ExpiresAt(key),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RotateSigningKey 生成新的签名密钥，旧密钥在宽限期内仍可验证已签发的令牌
+func (h *Handler) RotateSigningKey(c *gin.Context) {
+	var req models.RotateSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	key, err := h.keys.Rotate(req.Algorithm)
+	if errors.Is(err, keyring.ErrUnsupportedAlgorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的签名算法"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换签名密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "签名密钥已轮换",
+		"kid":       key.ID,
+		"algorithm": key.Algorithm,
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ldapTimeout LDAP 连接和查询的超时时间
+const ldapTimeout = 10 * time.Second
+
+// TestLDAPConnection 管理员测试 LDAP 配置，可选地测试指定用户的查找和绑定
+func (h *Handler) TestLDAPConnection(c *gin.Context) {
+	if h.ldap == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未配置 LDAP"})
+		return
+	}
+
+	var req models.LDAPTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.ldap.Test(req.Username, req.Password))
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// throttlePolicy 登录失败限制策略，来自系统配置
+type throttlePolicy struct {
+	maxFailures map[string]int
+	lockout     time.Duration
+	backoff     time.Duration
+}
+
+func (h *Handler) loadThrottlePolicy() throttlePolicy {
+	return throttlePolicy{
+		maxFailures: map[string]int{
+			models.ThrottleKindUsername: models.GetOptionInt(h.db, models.OptionLoginMaxFailures, 5),
+			models.ThrottleKindIP:       models.GetOptionInt(h.db, models.OptionLoginIPMaxFailures, 20),
+		},
+		lockout: time.Duration(models.GetOptionInt(h.db, models.OptionLoginLockoutMinutes, 15)) * time.Minute,
+		backoff: time.Duration(models.GetOptionInt(h.db, models.OptionLoginBackoffSeconds, 1)) * time.Second,
+	}
+}
+
+// delay 返回连续失败 failures 次后需要等待的退避时间，最长不超过锁定时长
+func (p throttlePolicy) delay(failures int) time.Duration {
+	if failures <= 0 || p.backoff <= 0 {
+		return 0
+	}
+	delay := time.Duration(float64(p.backoff) * math.Pow(2, float64(failures-1)))
+	if delay > p.lockout || delay <= 0 {
+		return p.lockout
+	}
+	return delay
+}
+
+// expired 判断失败记录是否已过期，过期后重新计数
+func (p throttlePolicy) expired(t *models.LoginThrottle, now time.Time) bool {
+	if t.LockedUntil != nil {
+		return now.After(*t.LockedUntil)
+	}
+	return now.Sub(t.LastFailureAt) > p.lockout
+}
+
+// throttleWriteAttempts 记录登录失败的最多尝试次数，SQLite 写锁竞争时短暂等待后重试
+const throttleWriteAttempts = 3
+
+// loginRetryAfter 返回用户名和IP还需等待多久才能再次尝试登录，0 表示允许；
+// 读取失败时返回错误，调用方应拒绝登录，不能在无法判断是否锁定时放行
+func (h *Handler) loginRetryAfter(username, ip string) (time.Duration, error) {
+	policy := h.loadThrottlePolicy()
+	now := time.Now()
+
+	var throttles []models.LoginThrottle
+	if err := h.db.Where("(kind = ? AND subject = ?) OR (kind = ? AND subject = ?)",
+		models.ThrottleKindUsername, username, models.ThrottleKindIP, ip).Find(&throttles).Error; err != nil {
+		log.Printf("Failed to load login throttles for %s/%s: %v", username, ip, err)
+		return 0, err
+	}
+
+	var wait time.Duration
+	for i := range throttles {
+		t := &throttles[i]
+		if policy.expired(t, now) {
+			continue
+		}
+
+		until := t.LastFailureAt.Add(policy.delay(t.Failures))
+		if t.LockedUntil != nil {
+			until = *t.LockedUntil
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// recordLoginFailure 记录一次登录失败，无论用户名是否存在都会计数；
+// 写入失败时重试，仍失败则返回错误，调用方应返回错误而不是按普通的密码错误处理
+func (h *Handler) recordLoginFailure(username, ip string) error {
+	policy := h.loadThrottlePolicy()
+	now := time.Now()
+
+	subjects := map[string]string{
+		models.ThrottleKindUsername: username,
+		models.ThrottleKindIP:       ip,
+	}
+	for kind, subject := range subjects {
+		var err error
+		for attempt := 1; attempt <= throttleWriteAttempts; attempt++ {
+			if err = h.incrementLoginThrottle(policy, kind, subject, now); err == nil {
+				break
+			}
+			time.Sleep(time.Duration(attempt) * 50 * time.Millisecond)
+		}
+		if err != nil {
+			log.Printf("Failed to record login failure for %s %s: %v", kind, subject, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// incrementLoginThrottle 增加一条失败计数，达到上限时锁定
+func (h *Handler) incrementLoginThrottle(policy throttlePolicy, kind, subject string, now time.Time) error {
+	return h.db.Transaction(func(tx *gorm.DB) error {
+		var t models.LoginThrottle
+		err := tx.Where("kind = ? AND subject = ?", kind, subject).First(&t).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			t = models.LoginThrottle{Kind: kind, Subject: subject}
+		} else if err != nil {
+			return err
+		} else if policy.expired(&t, now) {
+			t.Failures = 0
+			t.LockedUntil = nil
+		}
+
+		t.Failures++
+		t.LastFailureAt = now
+		if limit := policy.maxFailures[kind]; limit > 0 && t.Failures >= limit {
+			until := now.Add(policy.lockout)
+			t.LockedUntil = &until
+		}
+		return tx.Save(&t).Error
+	})
+}
+
+// clearLoginFailures 登录成功后清除该用户名的失败记录；IP 记录保留，避免攻击者用自己的账户重置计数
+func (h *Handler) clearLoginFailures(username string) {
+	h.db.Where("kind = ? AND subject = ?", models.ThrottleKindUsername, username).Delete(&models.LoginThrottle{})
+}
+
+// rejectThrottleUnavailable 无法读取或记录登录失败时拒绝登录
+func rejectThrottleUnavailable(c *gin.Context) {
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "登录服务暂不可用，请稍后再试"})
+}
+
+// rejectThrottledLogin 登录受限时返回 429 并告知客户端等待时间
+func rejectThrottledLogin(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "登录尝试过于频繁，请稍后再试",
+		"retry_after": seconds,
+	})
+}
+
+// ListLoginThrottles 管理员查看登录失败和锁定记录
+func (h *Handler) ListLoginThrottles(c *gin.Context) {
+	query := h.db.Order("updated_at desc")
+	if c.Query("locked") == "true" {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+
+	var throttles []models.LoginThrottle
+	if err := query.Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取锁定列表失败"})
+		return
+	}
+
+	now := time.Now()
+	response := make([]models.LoginThrottleResponse, 0, len(throttles))
+	for _, t := range throttles {
+		response = append(response, models.LoginThrottleResponse{
+			ID:            t.ID,
+			Kind:          t.Kind,
+			Subject:       t.Subject,
+			Failures:      t.Failures,
+			LastFailureAt: t.LastFailureAt,
+			LockedUntil:   t.LockedUntil,
+			Locked:        t.LockedUntil != nil && t.LockedUntil.After(now),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ClearLoginThrottle 管理员解除锁定
+func (h *Handler) ClearLoginThrottle(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的记录ID"})
+		return
+	}
+
+	result := h.db.Delete(&models.LoginThrottle{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除锁定失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "记录不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "锁定已解除"})
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// setOption 直接修改系统配置
+func setOption(t *testing.T, s *testServer, name, value string) {
+	t.Helper()
+	if err := s.db.Model(&models.Option{}).Where("option_name = ?", name).Update("option_value", value).Error; err != nil {
+		t.Fatalf("set option %s: %v", name, err)
+	}
+}
+
+// newLockoutTest 关闭验证码和退避，连续失败 3 次后锁定
+func newLockoutTest(t *testing.T) *testServer {
+	t.Helper()
+	s := newTestServer(t, nil)
+	setOption(t, s, models.OptionLoginCaptchaThreshold, "0")
+	setOption(t, s, models.OptionLoginBackoffSeconds, "0")
+	setOption(t, s, models.OptionLoginMaxFailures, "3")
+	setOption(t, s, models.OptionLoginIPMaxFailures, "5")
+	return s
+}
+
+func loginAttempt(t *testing.T, s *testServer, addr, username, password string) int {
+	t.Helper()
+	return s.doFrom(t, addr, http.MethodPost, "/api/login", models.LoginRequest{Username: username, Password: password}).Code
+}
+
+func TestLoginLockoutThresholdAndExpiry(t *testing.T) {
+	s := newLockoutTest(t)
+	s.createUser(t, "bob")
+
+	for i := 1; i <= 3; i++ {
+		if code := loginAttempt(t, s, "198.51.100.1:4000", "bob", "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d", i, code)
+		}
+	}
+	// 达到阈值后正确的密码也被拒绝，换 IP 也一样
+	rec := s.doFrom(t, "198.51.100.2:4000", http.MethodPost, "/api/login", models.LoginRequest{Username: "bob", Password: testPassword})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked account: status %d, headers %v", rec.Code, rec.Header())
+	}
+
+	// 锁定到期后可以登录，且失败计数被清除
+	past := time.Now().Add(-time.Second)
+	if err := s.db.Model(&models.LoginThrottle{}).Where("kind = ?", models.ThrottleKindUsername).
+		Updates(map[string]interface{}{"locked_until": past, "last_failure_at": past}).Error; err != nil {
+		t.Fatalf("expire lockout: %v", err)
+	}
+	if code := loginAttempt(t, s, "198.51.100.2:4000", "bob", testPassword); code != http.StatusOK {
+		t.Fatalf("login after lockout expired: status %d", code)
+	}
+	var count int64
+	s.db.Model(&models.LoginThrottle{}).Where("kind = ? AND subject = ?", models.ThrottleKindUsername, "bob").Count(&count)
+	if count != 0 {
+		t.Fatal("successful login should clear the username failures")
+	}
+}
+
+func TestLoginLockoutByIP(t *testing.T) {
+	s := newLockoutTest(t)
+	s.createUser(t, "bob")
+
+	// 同一 IP 尝试不同的用户名，按 IP 计数
+	for i := 1; i <= 5; i++ {
+		if code := loginAttempt(t, s, "203.0.113.9:4000", fmt.Sprintf("user%d", i), "wrong-password"); code != http.StatusUnauthorized {
+			t.Fatalf("failure %d: status %d", i, code)
+		}
+	}
+	if code := loginAttempt(t, s, "203.0.113.9:4000", "bob", testPassword); code != http.StatusTooManyRequests {
+		t.Fatalf("locked IP: status %d", code)
+	}
+	if code := loginAttempt(t, s, "198.51.100.1:4000", "bob", testPassword); code != http.StatusOK {
+		t.Fatalf("other IP: status %d", code)
+	}
+}
+
+func TestAdminClearsLockout(t *testing.T) {
+	s := newLockoutTest(t)
+	s.createAdmin(t, "alice")
+	s.createUser(t, "bob")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+
+	for i := 0; i < 3; i++ {
+		loginAttempt(t, s, "198.51.100.1:4000", "bob", "wrong-password")
+	}
+	var throttle models.LoginThrottle
+	if err := s.db.Where("kind = ? AND subject = ?", models.ThrottleKindUsername, "bob").First(&throttle).Error; err != nil {
+		t.Fatalf("load throttle: %v", err)
+	}
+	if rec := s.do(t, http.MethodDelete, fmt.Sprintf("/api/admin/lockouts/%d", throttle.ID), nil, admin...); rec.Code != http.StatusOK {
+		t.Fatalf("clear lockout: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if code := loginAttempt(t, s, "198.51.100.2:4000", "bob", testPassword); code != http.StatusOK {
+		t.Fatalf("login after lockout cleared: status %d", code)
+	}
+}
+
+func TestLoginFailsClosedWhenFailuresCannotBeRecorded(t *testing.T) {
+	s := newLockoutTest(t)
+	s.createUser(t, "bob")
+
+	// 模拟 SQLite 写锁竞争，失败计数无法写入
+	failWrites := func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "login_throttles" {
+			tx.AddError(errors.New("database is locked"))
+		}
+	}
+	s.db.Callback().Create().Before("gorm:create").Register("test:fail_throttle_create", failWrites)
+	s.db.Callback().Update().Before("gorm:update").Register("test:fail_throttle_update", failWrites)
+
+	if code := loginAttempt(t, s, "198.51.100.1:4000", "bob", "wrong-password"); code != http.StatusServiceUnavailable {
+		t.Fatalf("unrecorded failure: status %d", code)
+	}
+}
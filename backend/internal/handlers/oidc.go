@@ -0,0 +1,506 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/middleware"
+	"backend/internal/models"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// oidcStateTTL 从跳转到身份提供方到回调的最长时间
+const oidcStateTTL = 10 * time.Minute
+
+// oidcLoginCodeTTL 回调跳转回前端后换取令牌的最长时间
+const oidcLoginCodeTTL = time.Minute
+
+var (
+	errOIDCNotProvisioned = errors.New("oidc user not provisioned")
+	errOIDCInvalidClaims  = errors.New("oidc claims missing subject")
+	errOIDCLinkRequired   = errors.New("oidc email matches an account that must be linked explicitly")
+	errOIDCLinkedToOther  = errors.New("oidc identity linked to another user")
+)
+
+// oidcClient 已完成发现的身份提供方
+type oidcClient struct {
+	provider *oidc.Provider
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func (h *Handler) oidcEnabled() bool {
+	return h.cfg.OIDCIssuer != "" && h.cfg.OIDCClientID != ""
+}
+
+// oidcClient 首次使用时向身份提供方获取配置，失败时下次请求重试
+func (h *Handler) oidcClient(ctx context.Context) (*oidcClient, error) {
+	h.oidcMu.Lock()
+	defer h.oidcMu.Unlock()
+	if h.oidc != nil {
+		return h.oidc, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, h.cfg.OIDCIssuer)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL := h.cfg.OIDCRedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimRight(h.cfg.PublicURL, "/") + "/api/oidc/callback"
+	}
+
+	h.oidc = &oidcClient{
+		provider: provider,
+		oauth2: oauth2.Config{
+			ClientID:     h.cfg.OIDCClientID,
+			ClientSecret: h.cfg.OIDCClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       h.cfg.OIDCScopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: h.cfg.OIDCClientID}),
+	}
+	return h.oidc, nil
+}
+
+// oidcRedirect 回调结束后跳转回前端，参数放在 URL 片段中，不会发送到服务器或记录在日志里
+func (h *Handler) oidcRedirect(c *gin.Context, values url.Values) {
+	target := strings.TrimRight(h.cfg.PublicURL, "/") + "/oidc/callback#" + values.Encode()
+	c.Redirect(http.StatusFound, target)
+}
+
+func (h *Handler) oidcError(c *gin.Context, message string) {
+	h.oidcRedirect(c, url.Values{"error": {message}})
+}
+
+// BeginOIDCLogin 跳转到身份提供方进行单点登录，使用授权码模式和 PKCE
+func (h *Handler) BeginOIDCLogin(c *gin.Context) {
+	if authURL, ok := h.oidcAuthURL(c, nil); ok {
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// BeginOIDCLink 已登录用户关联单点登录账户，返回身份提供方的地址，由前端跳转；
+// 已有账户与外部身份邮箱相同但不能自动关联时使用
+func (h *Handler) BeginOIDCLink(c *gin.Context) {
+	value, _ := c.Get("user")
+	if authURL, ok := h.oidcAuthURL(c, &value.(*models.User).ID); ok {
+		c.JSON(http.StatusOK, gin.H{"url": authURL})
+	}
+}
+
+// oidcAuthURL 保存 state、nonce 和 PKCE 验证码，返回身份提供方的授权地址；失败时已写入响应
+func (h *Handler) oidcAuthURL(c *gin.Context, linkUserID *uint) (string, bool) {
+	if !h.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+		return "", false
+	}
+
+	client, err := h.oidcClient(c.Request.Context())
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "无法连接身份提供方"})
+		return "", false
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+		return "", false
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+		return "", false
+	}
+	binding, err := generateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+		return "", false
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	record := models.OIDCState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		BindingHash:  hashToken(binding),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := h.db.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发起单点登录失败"})
+		return "", false
+	}
+	middleware.SetOIDCBindingCookie(c, h.cfg, binding, oidcStateTTL)
+
+	return client.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), true
+}
+
+// OIDCCallback 处理身份提供方的回调，换取并校验 ID Token 后登录
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if !h.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未启用单点登录"})
+		return
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		log.Printf("OIDC provider returned error: %s %s", errCode, c.Query("error_description"))
+		h.oidcError(c, "身份提供方拒绝了登录请求")
+		return
+	}
+
+	// 回调必须来自发起请求的浏览器，否则攻击者可以把自己的回调地址发给他人，
+	// 使对方登录攻击者的账户或把攻击者的外部身份关联到对方的账户
+	binding, _ := c.Cookie(middleware.OIDCBindingCookie)
+	middleware.ClearOIDCBindingCookie(c, h.cfg)
+
+	// state 只能使用一次
+	var state models.OIDCState
+	if err := h.db.Where("state = ? AND expires_at > ?", c.Query("state"), time.Now()).First(&state).Error; err != nil {
+		h.oidcError(c, "登录请求无效或已过期，请重新登录")
+		return
+	}
+	if binding == "" || subtle.ConstantTimeCompare([]byte(hashToken(binding)), []byte(state.BindingHash)) != 1 {
+		log.Printf("OIDC callback rejected: state %d was started in another browser", state.ID)
+		h.oidcError(c, "登录请求无效或已过期，请重新登录")
+		return
+	}
+	if result := h.db.Delete(&state); result.Error != nil || result.RowsAffected == 0 {
+		h.oidcError(c, "登录请求无效或已过期，请重新登录")
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.oidcClient(ctx)
+	if err != nil {
+		log.Printf("OIDC discovery failed: %v", err)
+		h.oidcError(c, "无法连接身份提供方")
+		return
+	}
+
+	token, err := client.oauth2.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		log.Printf("OIDC code exchange failed: %v", err)
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := client.verifier.Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != state.Nonce {
+		log.Printf("OIDC ID token rejected: %v", err)
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+
+	// ID Token 中没有的声明从 userinfo 接口补充，提供方不支持时忽略
+	if info, err := client.provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil && info.Subject == idToken.Subject {
+		extra := map[string]interface{}{}
+		if err := info.Claims(&extra); err == nil {
+			for key, value := range extra {
+				if _, ok := claims[key]; !ok {
+					claims[key] = value
+				}
+			}
+		}
+	}
+
+	if state.LinkUserID != nil {
+		h.finishOIDCLink(c, *state.LinkUserID, idToken.Issuer, idToken.Subject)
+		return
+	}
+
+	user, err := h.resolveOIDCUser(idToken.Issuer, claims)
+	if errors.Is(err, errOIDCNotProvisioned) {
+		username, _ := claims[h.cfg.OIDCUsernameClaim].(string)
+		h.recordLoginEvent(c, nil, username, models.LoginMethodOIDC, "账户尚未开通")
+		h.oidcError(c, "该账户尚未开通，请联系管理员")
+		return
+	}
+	if errors.Is(err, errOIDCLinkRequired) {
+		username, _ := claims[h.cfg.OIDCUsernameClaim].(string)
+		h.recordLoginEvent(c, nil, username, models.LoginMethodOIDC, "同邮箱账户需要手动关联")
+		h.oidcError(c, "已存在使用该邮箱的账户，请先用该账户登录后关联单点登录")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC user provisioning failed: %v", err)
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+
+	// 跳转 URL 可能留在浏览器历史和代理日志中，只携带短期有效的一次性交换码，由前端 POST 换取令牌；
+	// 账户状态、来源 IP 和两步验证在换取令牌时与密码登录一样检查
+	code, err := generateOpaqueToken()
+	if err != nil {
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+	record := models.OIDCLoginCode{
+		CodeHash:  hashToken(code),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(oidcLoginCodeTTL),
+	}
+	if err := h.db.Create(&record).Error; err != nil {
+		h.oidcError(c, "单点登录失败")
+		return
+	}
+
+	h.oidcRedirect(c, url.Values{"code": {code}})
+}
+
+// ExchangeOIDCCode 用单点登录回调签发的交换码换取令牌，交换码只能使用一次；
+// 启用两步验证的用户与密码登录一样返回挑战令牌
+func (h *Handler) ExchangeOIDCCode(c *gin.Context) {
+	var req models.OIDCExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	var record models.OIDCLoginCode
+	if err := h.db.Where("code_hash = ? AND expires_at > ?", hashToken(req.Code), time.Now()).First(&record).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录请求无效或已过期，请重新登录"})
+		return
+	}
+	// 并发使用同一交换码时只有删除成功的请求可以继续
+	if result := h.db.Delete(&record); result.Error != nil || result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录请求无效或已过期，请重新登录"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, record.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录请求无效或已过期，请重新登录"})
+		return
+	}
+
+	h.finishFirstFactor(c, &user, models.LoginMethodOIDC, false)
+}
+
+// finishOIDCLink 把外部身份关联到发起关联的用户，完成后跳转回前端
+func (h *Handler) finishOIDCLink(c *gin.Context, userID uint, issuer, subject string) {
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", issuer, subject).First(&identity).Error
+		if err == nil {
+			if identity.UserID != user.ID {
+				return errOIDCLinkedToOther
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{UserID: user.ID, Provider: issuer, Subject: subject}).Error
+	})
+	if errors.Is(err, errOIDCLinkedToOther) {
+		h.oidcError(c, "该单点登录账户已关联其他用户")
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC identity link failed: %v", err)
+		h.oidcError(c, "关联单点登录账户失败")
+		return
+	}
+
+	h.recordAccountEvent(c, &user, models.SecurityEventIdentityLinked, issuer)
+	h.oidcRedirect(c, url.Values{"linked": {"1"}})
+}
+
+// resolveOIDCUser 按外部身份查找用户；未关联时按已验证的邮箱关联已有用户，或自动创建用户
+func (h *Handler) resolveOIDCUser(issuer string, claims map[string]interface{}) (*models.User, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errOIDCInvalidClaims
+	}
+
+	// 只信任身份提供方确认过的邮箱，避免通过未验证的邮箱接管已有账户
+	email := ""
+	if verified, _ := claims["email_verified"].(bool); verified {
+		email, _ = claims[h.cfg.OIDCEmailClaim].(string)
+		email = normalizeEmail(email)
+	}
+
+	var user models.User
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", issuer, subject).First(&identity).Error
+		if err == nil {
+			if err := tx.First(&user, identity.UserID).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		} else {
+			if err := h.linkOrProvisionOIDCUser(tx, &user, claims, subject, email); err != nil {
+				return err
+			}
+			identity = models.UserIdentity{UserID: user.ID, Provider: issuer, Subject: subject}
+			if err := tx.Create(&identity).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&identity).Update("last_login_at", now).Error; err != nil {
+			return err
+		}
+
+		// 按组声明同步 admin 角色，只同步单点登录创建的用户；关联了外部身份的本地用户的角色由管理员管理
+		if h.cfg.OIDCAdminGroup != "" && user.Source == models.UserSourceOIDC {
+			isAdmin := containsGroup(claims[h.cfg.OIDCGroupsClaim], h.cfg.OIDCAdminGroup)
+			changed, err := models.AssignRole(tx, user.ID, models.RoleAdmin, isAdmin)
+			if err != nil {
+				return err
+			}
+			if changed {
+				event := models.SecurityEvent{
+					UserID:   &user.ID,
+					Username: user.Username,
+					Type:     models.SecurityEventRoleChanged,
+					Detail:   models.RoleChangeDetail(models.RoleAdmin, isAdmin) + "（身份提供方组同步）",
+				}
+				if err := tx.Create(&event).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// linkOrProvisionOIDCUser 首次使用单点登录时关联同邮箱的用户，没有时自动创建；
+// 本地邮箱未验证或用户拥有管理权限时不自动关联，需由该用户登录后通过 BeginOIDCLink 关联
+func (h *Handler) linkOrProvisionOIDCUser(tx *gorm.DB, user *models.User, claims map[string]interface{}, subject, email string) error {
+	if email != "" {
+		err := tx.Unscoped().Where("email = ?", email).First(user).Error
+		if err == nil {
+			// 管理员可能在未验证的情况下设置过邮箱，已删除的用户和服务账户也不能被接管
+			if user.DeletedAt.Valid || user.IsServiceAccount() || user.EmailVerifiedAt == nil {
+				return errOIDCLinkRequired
+			}
+			if err := models.LoadUserRoles(tx, user); err != nil {
+				return err
+			}
+			if user.IsAdmin() {
+				return errOIDCLinkRequired
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	if !h.cfg.OIDCAutoProvision {
+		return errOIDCNotProvisioned
+	}
+
+	username, err := uniqueUsername(tx, oidcUsername(claims[h.cfg.OIDCUsernameClaim], email, subject))
+	if err != nil {
+		return err
+	}
+
+	// 单点登录用户没有可用的本地密码
+	hashedPassword, err := auth.UnusablePasswordHash()
+	if err != nil {
+		return err
+	}
+
+	*user = models.User{
+		Username: username,
+		Password: hashedPassword,
+		Email:    optionalEmail(email),
+		Source:   models.UserSourceOIDC,
+	}
+	if user.Email != nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	return tx.Create(user).Error
+}
+
+var usernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// oidcUsername 依次使用用户名声明、邮箱前缀和 subject 生成用户名
+func oidcUsername(claim interface{}, email, subject string) string {
+	candidates := []string{}
+	if name, ok := claim.(string); ok {
+		candidates = append(candidates, name)
+	}
+	if at := strings.Index(email, "@"); at > 0 {
+		candidates = append(candidates, email[:at])
+	}
+	for _, candidate := range candidates {
+		if name := usernameSanitizer.ReplaceAllString(candidate, "-"); strings.Trim(name, "-") != "" {
+			return name
+		}
+	}
+
+	if len(subject) > 16 {
+		subject = subject[:16]
+	}
+	return "oidc-" + usernameSanitizer.ReplaceAllString(subject, "-")
+}
+
+// uniqueUsername 用户名已被占用时追加数字后缀
+func uniqueUsername(tx *gorm.DB, base string) (string, error) {
+	name := base
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", name).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// containsGroup 组声明可能是字符串数组或单个字符串
+func containsGroup(claim interface{}, group string) bool {
+	switch groups := claim.(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok && s == group {
+				return true
+			}
+		}
+	case string:
+		return groups == group
+	}
+	return false
+}
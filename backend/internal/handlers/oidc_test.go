@@ -0,0 +1,376 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"backend/internal/auth/oidctest"
+	"backend/internal/middleware"
+	"backend/internal/models"
+)
+
+const testPublicURL = "http://app.test"
+
+// newOIDCTest 启动模拟身份提供方和后端，env 覆盖默认的 OIDC 配置
+func newOIDCTest(t *testing.T, claims map[string]interface{}, env map[string]string) (*testServer, *oidctest.Provider) {
+	t.Helper()
+	provider, err := oidctest.New("", "go-admin", "secret", claims)
+	if err != nil {
+		t.Fatalf("oidctest.New: %v", err)
+	}
+	idp := httptest.NewServer(provider)
+	t.Cleanup(idp.Close)
+	provider.Issuer = idp.URL
+
+	settings := map[string]string{
+		"PUBLIC_URL":         testPublicURL,
+		"OIDC_ISSUER":        idp.URL,
+		"OIDC_CLIENT_ID":     "go-admin",
+		"OIDC_CLIENT_SECRET": "secret",
+		"OIDC_ADMIN_GROUP":   "admins",
+	}
+	for key, value := range env {
+		settings[key] = value
+	}
+	return newTestServer(t, settings), provider
+}
+
+func aliceClaims(groups ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":                "alice-sub",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"groups":             groups,
+	}
+}
+
+// beginOIDC 调用 BeginOIDCLogin 并检查跳转到身份提供方的授权请求，返回授权地址和浏览器绑定 Cookie
+func beginOIDC(t *testing.T, s *testServer) (string, string) {
+	t.Helper()
+	rec := s.do(t, http.MethodGet, "/api/oidc/login", nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	return checkAuthURL(t, rec.Header().Get("Location")), bindingCookie(t, rec)
+}
+
+// checkAuthURL 检查授权请求包含 PKCE、nonce 和 state
+func checkAuthURL(t *testing.T, location string) string {
+	t.Helper()
+	authURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	q := authURL.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("nonce") == "" || q.Get("state") == "" {
+		t.Fatalf("authorization request lacks PKCE, nonce or state: %s", authURL)
+	}
+	return authURL.String()
+}
+
+// bindingCookie 返回响应设置的绑定 Cookie，格式为 name=value，可直接作为 Cookie 请求头
+func bindingCookie(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == middleware.OIDCBindingCookie && cookie.Value != "" {
+			if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+				t.Fatalf("binding cookie must be HttpOnly and SameSite=Lax: %+v", cookie)
+			}
+			return cookie.Name + "=" + cookie.Value
+		}
+	}
+	t.Fatalf("no binding cookie set: %v", rec.Header().Values("Set-Cookie"))
+	return ""
+}
+
+// startOIDC 发起单点登录并在身份提供方完成授权，返回回调路径和浏览器绑定 Cookie
+func startOIDC(t *testing.T, s *testServer) (string, string) {
+	t.Helper()
+	authURL, cookie := beginOIDC(t, s)
+	return authorizeOIDC(t, authURL), cookie
+}
+
+// authorizeOIDC 访问身份提供方的授权地址，返回回调到后端的路径
+func authorizeOIDC(t *testing.T, authURL string) string {
+	t.Helper()
+	resp, err := noRedirectClient.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(callback.String(), testPublicURL+"/api/oidc/callback") {
+		t.Fatalf("unexpected authorize redirect %q", resp.Header.Get("Location"))
+	}
+	return callback.RequestURI()
+}
+
+// callbackOIDC 携带绑定 Cookie 调用 OIDCCallback，返回跳转回前端的 URL 片段参数
+func callbackOIDC(t *testing.T, s *testServer, callback, cookie string) url.Values {
+	t.Helper()
+	headers := []string{}
+	if cookie != "" {
+		headers = append(headers, "Cookie", cookie)
+	}
+	rec := s.do(t, http.MethodGet, callback, nil, headers...)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(target.String(), testPublicURL+"/oidc/callback#") {
+		t.Fatalf("unexpected callback redirect %q", rec.Header().Get("Location"))
+	}
+	if target.RawQuery != "" || strings.Contains(target.Fragment, "token") {
+		t.Fatalf("callback redirect must only carry the exchange code: %s", target)
+	}
+	values, err := url.ParseQuery(target.Fragment)
+	if err != nil {
+		t.Fatalf("parse fragment: %v", err)
+	}
+	return values
+}
+
+// completeOIDC 在同一浏览器中完成授权和回调，返回跳转回前端的 URL 片段参数
+func completeOIDC(t *testing.T, s *testServer) url.Values {
+	t.Helper()
+	callback, cookie := startOIDC(t, s)
+	return callbackOIDC(t, s, callback, cookie)
+}
+
+// loginOIDC 完成一次单点登录并用交换码换取令牌
+func loginOIDC(t *testing.T, s *testServer) map[string]interface{} {
+	t.Helper()
+	values := completeOIDC(t, s)
+	if values.Get("error") != "" || values.Get("code") == "" {
+		t.Fatalf("callback failed: %v", values)
+	}
+
+	rec := s.do(t, http.MethodPost, "/api/oidc/exchange", map[string]string{"code": values.Get("code")})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exchange: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	// 交换码只能使用一次
+	if again := s.do(t, http.MethodPost, "/api/oidc/exchange", map[string]string{"code": values.Get("code")}); again.Code != http.StatusUnauthorized {
+		t.Fatalf("reused exchange code: status %d", again.Code)
+	}
+	return decode(t, rec)
+}
+
+func userRoles(t *testing.T, response map[string]interface{}) []string {
+	t.Helper()
+	user, ok := response["user"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("response has no user: %v", response)
+	}
+	roles := []string{}
+	for _, role := range user["roles"].([]interface{}) {
+		roles = append(roles, role.(string))
+	}
+	return roles
+}
+
+func TestOIDCLoginProvisionsUserAndSyncsAdminGroup(t *testing.T) {
+	s, provider := newOIDCTest(t, aliceClaims("admins"), nil)
+
+	response := loginOIDC(t, s)
+	if response["token"] == "" || response["refresh_token"] == "" {
+		t.Fatalf("exchange did not return tokens: %v", response)
+	}
+	if roles := userRoles(t, response); len(roles) != 1 || roles[0] != models.RoleAdmin {
+		t.Fatalf("admin group member should get the admin role, got %v", roles)
+	}
+
+	var user models.User
+	if err := s.db.Where("username = ?", "alice").First(&user).Error; err != nil {
+		t.Fatalf("user was not provisioned: %v", err)
+	}
+	if user.Source != models.UserSourceOIDC || user.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected provisioned user: source %q, email verified %v", user.Source, user.EmailVerifiedAt)
+	}
+
+	// 从管理员组移除后，下次登录时同步移除 admin 角色
+	provider.SetClaims(aliceClaims())
+	if roles := userRoles(t, loginOIDC(t, s)); len(roles) != 0 {
+		t.Fatalf("admin role should be removed, got %v", roles)
+	}
+
+	var count int64
+	s.db.Model(&models.User{}).Where("username LIKE ?", "alice%").Count(&count)
+	if count != 1 {
+		t.Fatalf("second login should reuse the linked user, found %d users", count)
+	}
+	s.db.Model(&models.SecurityEvent{}).Where("user_id = ? AND type = ?", user.ID, models.SecurityEventRoleChanged).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 role change events, got %d", count)
+	}
+}
+
+func TestOIDCAutoProvisionDisabled(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), map[string]string{"OIDC_AUTO_PROVISION": "false"})
+
+	values := completeOIDC(t, s)
+	if values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("unknown user should be rejected, got %v", values)
+	}
+	var count int64
+	s.db.Model(&models.User{}).Where("username = ?", "alice").Count(&count)
+	if count != 0 {
+		t.Fatal("user must not be provisioned")
+	}
+}
+
+func TestOIDCCallbackRejectsPKCEMismatch(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+
+	callback, cookie := startOIDC(t, s)
+	// 模拟授权码被截获后由不持有验证码的一方兑换
+	if err := s.db.Model(&models.OIDCState{}).Where("1 = 1").Update("code_verifier", "wrong-verifier-wrong-verifier-wrong-verifier").Error; err != nil {
+		t.Fatalf("update verifier: %v", err)
+	}
+	if values := callbackOIDC(t, s, callback, cookie); values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("PKCE mismatch should fail, got %v", values)
+	}
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	s, provider := newOIDCTest(t, aliceClaims(), nil)
+
+	replayed := "nonce-from-another-login"
+	provider.SetNonce(&replayed)
+	if values := completeOIDC(t, s); values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("nonce mismatch should fail, got %v", values)
+	}
+}
+
+func TestOIDCCallbackRejectsReusedState(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+
+	callback, cookie := startOIDC(t, s)
+	if values := callbackOIDC(t, s, callback, cookie); values.Get("code") == "" {
+		t.Fatalf("first callback failed: %v", values)
+	}
+	if values := callbackOIDC(t, s, callback, cookie); values.Get("error") == "" {
+		t.Fatalf("state must only be usable once, got %v", values)
+	}
+}
+
+func TestOIDCDoesNotAutoLinkAdmin(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+
+	// 管理员的邮箱与外部身份相同且已验证，也不能自动关联
+	if err := s.db.Model(&models.User{}).Where("username = ?", "admin").
+		Updates(map[string]interface{}{"email": "alice@example.com", "email_verified_at": "2024-01-01 00:00:00"}).Error; err != nil {
+		t.Fatalf("update admin: %v", err)
+	}
+	if values := completeOIDC(t, s); values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("admin account must not be auto-linked, got %v", values)
+	}
+	var count int64
+	s.db.Model(&models.UserIdentity{}).Count(&count)
+	if count != 0 {
+		t.Fatal("no identity should be linked")
+	}
+}
+
+func TestOIDCLoginRequiresTwoFactor(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+	loginOIDC(t, s)
+
+	if err := s.db.Model(&models.User{}).Where("username = ?", "alice").Update("totp_enabled", true).Error; err != nil {
+		t.Fatalf("enable 2FA: %v", err)
+	}
+	values := completeOIDC(t, s)
+	rec := s.do(t, http.MethodPost, "/api/oidc/exchange", map[string]string{"code": values.Get("code")})
+	response := decode(t, rec)
+	if rec.Code != http.StatusOK || response["two_factor_required"] != true || response["token"] != nil {
+		t.Fatalf("2FA user should get a challenge instead of tokens: %d %v", rec.Code, response)
+	}
+}
+
+func TestOIDCCallbackRequiresInitiatingBrowser(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+
+	// 攻击者发起登录并把回调地址发给受害者，受害者的浏览器没有绑定 Cookie
+	callback, attackerCookie := startOIDC(t, s)
+	if values := callbackOIDC(t, s, callback, ""); values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("callback without binding cookie should fail, got %v", values)
+	}
+	// 受害者自己发起过其他登录时，绑定 Cookie 也不匹配
+	_, victimCookie := beginOIDC(t, s)
+	if values := callbackOIDC(t, s, callback, victimCookie); values.Get("error") == "" || values.Get("code") != "" {
+		t.Fatalf("callback with another flow's binding cookie should fail, got %v", values)
+	}
+	if values := callbackOIDC(t, s, callback, attackerCookie); values.Get("code") == "" {
+		t.Fatalf("callback from the initiating browser should succeed, got %v", values)
+	}
+}
+
+func TestOIDCLinkRequiresInitiatingBrowser(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+	victim := s.createUser(t, "bob")
+
+	// 攻击者以自己的身份在身份提供方授权后，诱导受害者的浏览器完成关联回调
+	rec := s.do(t, http.MethodPost, "/api/user/oidc/link", nil, "Authorization", "Bearer "+s.login(t, "bob"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("begin link: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	bindingCookie(t, rec)
+	callback := authorizeOIDC(t, checkAuthURL(t, decode(t, rec)["url"].(string)))
+	if values := callbackOIDC(t, s, callback, ""); values.Get("error") == "" || values.Get("linked") != "" {
+		t.Fatalf("link callback without binding cookie should fail, got %v", values)
+	}
+
+	var count int64
+	s.db.Model(&models.UserIdentity{}).Where("user_id = ?", victim.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("identity must not be linked")
+	}
+}
+
+// linkOIDC 已登录用户关联单点登录账户
+func linkOIDC(t *testing.T, s *testServer, token string) {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/user/oidc/link", nil, "Authorization", "Bearer "+token)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("begin link: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	cookie := bindingCookie(t, rec)
+	callback := authorizeOIDC(t, checkAuthURL(t, decode(t, rec)["url"].(string)))
+	if values := callbackOIDC(t, s, callback, cookie); values.Get("linked") != "1" {
+		t.Fatalf("link failed: %v", values)
+	}
+}
+
+func TestOIDCGroupSyncSkipsLocalUsers(t *testing.T) {
+	s, provider := newOIDCTest(t, aliceClaims(), nil)
+
+	// 本地管理员关联外部身份后，身份提供方中没有管理员组也不会失去 admin 角色
+	s.createAdmin(t, "carol")
+	linkOIDC(t, s, s.login(t, "carol"))
+	if roles := userRoles(t, loginOIDC(t, s)); len(roles) != 1 || roles[0] != models.RoleAdmin {
+		t.Fatalf("linked local admin should keep the admin role, got %v", roles)
+	}
+
+	// 按已验证的邮箱自动关联的本地用户也不会因组声明获得 admin 角色
+	dave := s.createUser(t, "dave")
+	if err := s.db.Model(dave).Updates(map[string]interface{}{"email": "dave@example.com", "email_verified_at": "2024-01-01 00:00:00"}).Error; err != nil {
+		t.Fatalf("update dave: %v", err)
+	}
+	provider.SetClaims(map[string]interface{}{
+		"sub":            "dave-sub",
+		"email":          "dave@example.com",
+		"email_verified": true,
+		"groups":         []string{"admins"},
+	})
+	response := loginOIDC(t, s)
+	if user := response["user"].(map[string]interface{}); user["username"] != "dave" {
+		t.Fatalf("expected dave to be auto-linked, got %v", user)
+	}
+	if roles := userRoles(t, response); len(roles) != 0 {
+		t.Fatalf("local user must not get the admin role from groups, got %v", roles)
+	}
+}
@@ -0,0 +1,82 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func TestUpdateOptionsValidatesValues(t *testing.T) {
+	s := newTestServer(t, nil)
+	operator := s.createUser(t, "bob")
+	if _, err := models.AssignRole(s.db, operator.ID, models.RoleAdmin, true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	bearer := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	update := func(name, value string) int {
+		body := models.UpdateOptionsRequest{Options: []models.OptionUpdate{
+			{OptionName: models.OptionSystemName, OptionValue: "changed"},
+			{OptionName: name, OptionValue: value},
+		}}
+		return s.do(t, http.MethodPut, "/api/admin/options", body, bearer...).Code
+	}
+
+	rejected := []struct{ name, value string }{
+		{models.OptionSessionTTLHours, "-1"},
+		{models.OptionRememberMeTTLHours, "1.5"},
+		{models.OptionSessionIdleTimeoutMinutes, "abc"},
+		{models.OptionLoginMaxFailures, "-3"},
+		{models.OptionLoginLockoutMinutes, " 5"},
+		{models.OptionAllowRegistration, "yes"},
+		{models.OptionCaptchaType, "audio"},
+		{models.OptionCaptchaType, "Math"},
+		{"no_such_option", "1"},
+	}
+	for _, tt := range rejected {
+		if code := update(tt.name, tt.value); code != http.StatusBadRequest {
+			t.Errorf("%s=%q: status %d, want 400", tt.name, tt.value, code)
+		}
+	}
+	// 整批更新失败时不修改任何配置
+	if name := models.GetOptionValue(s.db, models.OptionSystemName); name == "changed" {
+		t.Fatal("rejected update must not change other options")
+	}
+
+	if code := update(models.OptionSessionIdleTimeoutMinutes, "0"); code != http.StatusOK {
+		t.Fatalf("valid update: status %d", code)
+	}
+	if got := models.GetOptionValue(s.db, models.OptionSessionIdleTimeoutMinutes); got != "0" {
+		t.Fatalf("option was not updated, got %q", got)
+	}
+	if code := update(models.OptionCaptchaType, "text"); code != http.StatusOK {
+		t.Fatalf("valid captcha type: status %d", code)
+	}
+}
+
+func TestIdleTimeoutUpdateAppliesImmediately(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+	user := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	// 读取一次配置，使其进入缓存
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, user...); rec.Code != http.StatusOK {
+		t.Fatalf("before update: status %d", rec.Code)
+	}
+	past := time.Now().Add(-10 * time.Minute)
+	if err := s.db.Model(&models.Session{}).Where("user_id = ?", bob.ID).UpdateColumn("last_seen_at", past).Error; err != nil {
+		t.Fatalf("age sessions: %v", err)
+	}
+
+	body := models.UpdateOptionsRequest{Options: []models.OptionUpdate{{OptionName: models.OptionSessionIdleTimeoutMinutes, OptionValue: "5"}}}
+	if rec := s.do(t, http.MethodPut, "/api/admin/options", body, admin...); rec.Code != http.StatusOK {
+		t.Fatalf("update options: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, user...); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("idle session after timeout update: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+)
+
+const (
+	passkeyPurposeRegister = "register"
+	passkeyPurposeLogin    = "login"
+	passkeyChallengeTTL    = 5 * time.Minute
+)
+
+var errInvalidPasskeyChallenge = errors.New("invalid passkey challenge")
+
+// newWebAuthn 根据配置创建通行密钥依赖方
+func newWebAuthn(rpID string, origins []string, displayName string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyChallengeTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: passkeyChallengeTTL},
+		},
+	})
+}
+
+// webAuthnUser 将用户及其通行密钥适配为 webauthn.User
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return webAuthnUserHandle(u.user.ID)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// webAuthnUserHandle 用户句柄，不包含用户名等可识别信息
+func webAuthnUserHandle(userID uint) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userID))
+	return handle
+}
+
+// loadWebAuthnUser 加载用户及其全部通行密钥
+func (h *Handler) loadWebAuthnUser(userID uint) (*webAuthnUser, error) {
+	var user models.User
+	if err := h.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var records []models.WebAuthnCredential
+	if err := h.db.Where("user_id = ?", userID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(records))
+	for _, record := range records {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(record.Data), &credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webAuthnUser{user: &user, credentials: credentials}, nil
+}
+
+// saveChallenge 保存仪式会话数据，返回交给客户端的仪式标识
+func (h *Handler) saveChallenge(userID uint, purpose string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	challengeID, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := models.WebAuthnChallenge{
+		ChallengeID: challengeID,
+		UserID:      userID,
+		Purpose:     purpose,
+		Data:        string(data),
+		ExpiresAt:   time.Now().Add(passkeyChallengeTTL),
+	}
+	if err := h.db.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return challengeID, nil
+}
+
+// consumeChallenge 取出并删除仪式会话数据，每个仪式只能完成一次
+func (h *Handler) consumeChallenge(challengeID string, userID uint, purpose string) (*webauthn.SessionData, error) {
+	var challenge models.WebAuthnChallenge
+	err := h.db.Where("challenge_id = ? AND user_id = ? AND purpose = ?", challengeID, userID, purpose).
+		First(&challenge).Error
+	if err != nil {
+		return nil, errInvalidPasskeyChallenge
+	}
+
+	result := h.db.Delete(&challenge)
+	if result.Error != nil || result.RowsAffected == 0 || time.Now().After(challenge.ExpiresAt) {
+		return nil, errInvalidPasskeyChallenge
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(challenge.Data), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// BeginPasskeyRegistration 开始为当前用户注册通行密钥
+func (h *Handler) BeginPasskeyRegistration(c *gin.Context) {
+	if h.webAuthn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "通行密钥功能未启用"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	waUser, err := h.loadWebAuthnUser(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	// 排除已注册的凭据，避免同一认证器重复注册
+	creation, session, err := h.webAuthn.BeginRegistration(waUser,
+		webauthn.WithExclusions(webauthn.Credentials(waUser.credentials).CredentialDescriptors()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建注册请求失败"})
+		return
+	}
+
+	challengeID, err := h.saveChallenge(currentUser.ID, passkeyPurposeRegister, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建注册请求失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      creation,
+	})
+}
+
+// FinishPasskeyRegistration 校验认证器的注册响应并保存通行密钥
+func (h *Handler) FinishPasskeyRegistration(c *gin.Context) {
+	if h.webAuthn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "通行密钥功能未启用"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	session, err := h.consumeChallenge(c.Query("challenge_id"), currentUser.ID, passkeyPurposeRegister)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "注册请求已过期，请重试"})
+		return
+	}
+
+	waUser, err := h.loadWebAuthnUser(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	credential, err := h.webAuthn.FinishRegistration(waUser, *session, c.Request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "通行密钥验证失败"})
+		return
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存通行密钥失败"})
+		return
+	}
+
+	name := c.Query("name")
+	if name == "" {
+		name = "通行密钥"
+	}
+
+	record := models.WebAuthnCredential{
+		UserID:       currentUser.ID,
+		Name:         name,
+		CredentialID: credential.ID,
+		Data:         string(data),
+	}
+	if err := h.db.Create(&record).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "通行密钥已存在"})
+		return
+	}
+	h.recordAccountEvent(c, currentUser, models.SecurityEventPasskeyAdded, name)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "通行密钥注册成功",
+		"passkey": toPasskeyResponse(record),
+	})
+}
+
+// BeginPasskeyLogin 开始通行密钥登录，使用可发现凭据无需输入用户名
+func (h *Handler) BeginPasskeyLogin(c *gin.Context) {
+	if h.webAuthn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "通行密钥功能未启用"})
+		return
+	}
+
+	assertion, session, err := h.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建登录请求失败"})
+		return
+	}
+
+	challengeID, err := h.saveChallenge(0, passkeyPurposeLogin, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建登录请求失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"challenge_id": challengeID,
+		"options":      assertion,
+	})
+}
+
+// FinishPasskeyLogin 校验认证器的断言并签发与密码登录相同的令牌；请求体为断言，
+// 因此 challenge_id 和 remember_me 通过查询参数传递
+func (h *Handler) FinishPasskeyLogin(c *gin.Context) {
+	if h.webAuthn == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "通行密钥功能未启用"})
+		return
+	}
+
+	session, err := h.consumeChallenge(c.Query("challenge_id"), 0, passkeyPurposeLogin)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "登录请求已过期，请重试"})
+		return
+	}
+
+	var waUser *webAuthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		if len(userHandle) != 8 {
+			return nil, errInvalidPasskeyChallenge
+		}
+		var err error
+		waUser, err = h.loadWebAuthnUser(uint(binary.BigEndian.Uint64(userHandle)))
+		return waUser, err
+	}
+
+	credential, err := h.webAuthn.FinishDiscoverableLogin(handler, *session, c.Request)
+	if err != nil || waUser == nil {
+		// 只有认证器给出的用户存在时才记入该用户的登录历史
+		if waUser != nil {
+			h.recordLoginEvent(c, waUser.user, waUser.user.Username, models.LoginMethodPasskey, "通行密钥验证失败")
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "通行密钥验证失败"})
+		return
+	}
+
+	var record models.WebAuthnCredential
+	err = h.db.Where("user_id = ? AND credential_id = ?", waUser.user.ID, credential.ID).First(&record).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通行密钥失败"})
+		return
+	}
+
+	// 签名计数没有增加说明可能存在被克隆的认证器，停用该通行密钥，用户需删除后重新注册
+	now := time.Now()
+	if credential.Authenticator.CloneWarning || record.CloneDetectedAt != nil {
+		if record.CloneDetectedAt == nil {
+			if err := h.db.Model(&record).Update("clone_detected_at", now).Error; err != nil {
+				log.Printf("Failed to flag cloned passkey %d: %v", record.ID, err)
+			}
+			h.recordAccountEvent(c, waUser.user, models.SecurityEventPasskeyCloneDetected, record.Name)
+		}
+		h.recordLoginEvent(c, waUser.user, waUser.user.Username, models.LoginMethodPasskey, "通行密钥疑似被克隆，已停用")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "该通行密钥疑似被复制，已停用，请使用其他方式登录后删除并重新注册"})
+		return
+	}
+
+	// 保存最新的签名计数，用于发现被克隆的认证器
+	data, err := json.Marshal(credential)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通行密钥失败"})
+		return
+	}
+	err = h.db.Model(&record).Updates(map[string]interface{}{"data": string(data), "last_used_at": now}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通行密钥失败"})
+		return
+	}
+
+	// 通行密钥要求用户验证，本身即满足多因素认证，无需再校验 TOTP
+	h.completeLogin(c, waUser.user, models.LoginMethodPasskey, c.Query("remember_me") == "true")
+}
+
+func toPasskeyResponse(record models.WebAuthnCredential) models.PasskeyResponse {
+	return models.PasskeyResponse{
+		ID:              record.ID,
+		Name:            record.Name,
+		LastUsedAt:      record.LastUsedAt,
+		CloneDetectedAt: record.CloneDetectedAt,
+		CreatedAt:       record.CreatedAt,
+	}
+}
+
+// ListPasskeys 列出当前用户的通行密钥
+func (h *Handler) ListPasskeys(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var records []models.WebAuthnCredential
+	if err := h.db.Where("user_id = ?", currentUser.ID).Order("created_at").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通行密钥列表失败"})
+		return
+	}
+
+	response := make([]models.PasskeyResponse, 0, len(records))
+	for _, record := range records {
+		response = append(response, toPasskeyResponse(record))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// findOwnPasskey 查找当前用户名下的指定通行密钥
+func (h *Handler) findOwnPasskey(c *gin.Context) (*models.WebAuthnCredential, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通行密钥ID"})
+		return nil, false
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var record models.WebAuthnCredential
+	if err := h.db.Where("id = ? AND user_id = ?", uint(id), currentUser.ID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "通行密钥不存在"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通行密钥失败"})
+		}
+		return nil, false
+	}
+	return &record, true
+}
+
+// RenamePasskey 重命名通行密钥
+func (h *Handler) RenamePasskey(c *gin.Context) {
+	var req models.RenamePasskeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	record, ok := h.findOwnPasskey(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Model(record).Update("name", req.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名通行密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "通行密钥已重命名",
+		"passkey": toPasskeyResponse(*record),
+	})
+}
+
+// DeletePasskey 删除通行密钥
+func (h *Handler) DeletePasskey(c *gin.Context) {
+	record, ok := h.findOwnPasskey(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除通行密钥失败"})
+		return
+	}
+	value, _ := c.Get("user")
+	h.recordAccountEvent(c, value.(*models.User), models.SecurityEventPasskeyRemoved, record.Name)
+
+	c.JSON(http.StatusOK, gin.H{"message": "通行密钥已删除"})
+}
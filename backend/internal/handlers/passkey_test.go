@@ -0,0 +1,141 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/auth/webauthntest"
+	"backend/internal/models"
+)
+
+// 默认配置允许的通行密钥来源
+const passkeyOrigin = "http://localhost:5173"
+
+// beginCeremony 开始注册或登录仪式，返回仪式标识和传给认证器的参数
+func beginCeremony(t *testing.T, s *testServer, target string, headers ...string) (string, []byte) {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, target, nil, headers...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d, body %s", target, rec.Code, rec.Body.String())
+	}
+	var response struct {
+		ChallengeID string          `json:"challenge_id"`
+		Options     json.RawMessage `json:"options"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode %s: %v", target, err)
+	}
+	return response.ChallengeID, response.Options
+}
+
+// registerPasskey 为已登录用户注册通行密钥
+func registerPasskey(t *testing.T, s *testServer, authenticator *webauthntest.Authenticator, token string) {
+	t.Helper()
+	bearer := []string{"Authorization", "Bearer " + token}
+	challengeID, options := beginCeremony(t, s, "/api/user/passkeys/register/begin", bearer...)
+	body, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("authenticator register: %v", err)
+	}
+	rec := s.do(t, http.MethodPost, "/api/user/passkeys/register/finish?name=laptop&challenge_id="+challengeID, body, bearer...)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("finish registration: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+// loginPasskey 不输入用户名，使用可发现凭据登录
+func loginPasskey(t *testing.T, s *testServer, authenticator *webauthntest.Authenticator) *httptest.ResponseRecorder {
+	t.Helper()
+	challengeID, options := beginCeremony(t, s, "/api/login/passkey/begin")
+	body, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("authenticator login: %v", err)
+	}
+	return s.do(t, http.MethodPost, "/api/login/passkey/finish?challenge_id="+challengeID, body)
+}
+
+func TestPasskeyRegistrationAndDiscoverableLogin(t *testing.T) {
+	s := newTestServer(t, nil)
+	user := s.createUser(t, "bob")
+	authenticator := webauthntest.New(passkeyOrigin)
+	registerPasskey(t, s, authenticator, s.login(t, "bob"))
+
+	rec := loginPasskey(t, s, authenticator)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("passkey login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	response := decode(t, rec)
+	if response["token"] == nil || response["user"].(map[string]interface{})["username"] != "bob" {
+		t.Fatalf("unexpected login response: %v", response)
+	}
+
+	var record models.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", user.ID).First(&record).Error; err != nil {
+		t.Fatalf("load passkey: %v", err)
+	}
+	if record.Name != "laptop" || record.LastUsedAt == nil || record.CloneDetectedAt != nil {
+		t.Fatalf("unexpected passkey record: %+v", record)
+	}
+
+	// 每个仪式只能完成一次
+	challengeID, options := beginCeremony(t, s, "/api/login/passkey/begin")
+	body, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("authenticator login: %v", err)
+	}
+	if rec := s.do(t, http.MethodPost, "/api/login/passkey/finish?challenge_id="+challengeID, body); rec.Code != http.StatusOK {
+		t.Fatalf("passkey login: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodPost, "/api/login/passkey/finish?challenge_id="+challengeID, body); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed assertion: status %d", rec.Code)
+	}
+}
+
+func TestPasskeyLoginRejectsWrongOrigin(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	authenticator := webauthntest.New(passkeyOrigin)
+	registerPasskey(t, s, authenticator, s.login(t, "bob"))
+
+	authenticator.Origin = "https://phishing.example"
+	if rec := loginPasskey(t, s, authenticator); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("assertion from another origin: status %d", rec.Code)
+	}
+}
+
+func TestPasskeyLoginDisablesClonedAuthenticator(t *testing.T) {
+	s := newTestServer(t, nil)
+	user := s.createUser(t, "bob")
+	authenticator := webauthntest.New(passkeyOrigin)
+	registerPasskey(t, s, authenticator, s.login(t, "bob"))
+	clone := authenticator.Clone()
+
+	if rec := loginPasskey(t, s, authenticator); rec.Code != http.StatusOK {
+		t.Fatalf("original authenticator: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	// 副本的签名计数没有超过已保存的值
+	if rec := loginPasskey(t, s, clone); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("cloned authenticator: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var record models.WebAuthnCredential
+	if err := s.db.Where("user_id = ?", user.ID).First(&record).Error; err != nil {
+		t.Fatalf("load passkey: %v", err)
+	}
+	if record.CloneDetectedAt == nil {
+		t.Fatal("passkey should be flagged as cloned")
+	}
+
+	// 停用后原认证器也不能再登录，且只记录一次安全事件
+	if rec := loginPasskey(t, s, authenticator); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("flagged passkey: status %d", rec.Code)
+	}
+	var count int64
+	s.db.Model(&models.SecurityEvent{}).
+		Where("user_id = ? AND type = ?", user.ID, models.SecurityEventPasskeyCloneDetected).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected 1 clone event, got %d", count)
+	}
+}
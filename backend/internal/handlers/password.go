@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/internal/mailer"
+	"backend/internal/models"
+	"backend/internal/password"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// adminResetLinkTTL 管理员生成的重置链接有效期
+	adminResetLinkTTL = 24 * time.Hour
+	// temporaryPasswordLength 管理员重置时生成的临时密码长度
+	temporaryPasswordLength = 16
+	// forgotPasswordTTL 用户自助找回密码的链接有效期
+	forgotPasswordTTL = time.Hour
+	// forgotPasswordInterval 同一用户两次发送重置邮件的最短间隔
+	forgotPasswordInterval = time.Minute
+)
+
+var errInvalidResetToken = errors.New("invalid password reset token")
+
+// passwordPolicy 从系统配置加载密码策略
+func (h *Handler) passwordPolicy() password.Policy {
+	return password.Policy{
+		MinLength:        models.GetOptionInt(h.db, models.OptionPasswordMinLength, 8),
+		RequireLowercase: models.GetOptionValue(h.db, models.OptionPasswordRequireLowercase) == "true",
+		RequireUppercase: models.GetOptionValue(h.db, models.OptionPasswordRequireUppercase) == "true",
+		RequireDigit:     models.GetOptionValue(h.db, models.OptionPasswordRequireDigit) == "true",
+		RequireSymbol:    models.GetOptionValue(h.db, models.OptionPasswordRequireSymbol) == "true",
+		ForbidUsername:   models.GetOptionValue(h.db, models.OptionPasswordForbidUsername) == "true",
+		RejectCommon:     models.GetOptionValue(h.db, models.OptionPasswordRejectCommon) == "true",
+		HistoryCount:     models.GetOptionInt(h.db, models.OptionPasswordHistoryCount, 5),
+	}
+}
+
+// validatePassword 检查新密码是否符合策略，已存在的用户同时检查历史密码
+func (h *Handler) validatePassword(user *models.User, newPassword string) error {
+	policy := h.passwordPolicy()
+	reused := user.ID != 0 && policy.HistoryCount > 0 && h.passwordReused(user, newPassword, policy.HistoryCount)
+	return policy.Validate(newPassword, user.Username, reused)
+}
+
+// passwordReused 判断密码是否与当前密码或最近使用过的密码相同
+func (h *Handler) passwordReused(user *models.User, newPassword string, count int) bool {
+	hashes := []string{user.Password}
+
+	var history []models.PasswordHistory
+	h.db.Where("user_id = ?", user.ID).Order("id desc").Limit(count - 1).Find(&history)
+	for _, entry := range history {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(newPassword)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setPassword 更新用户密码，并将旧密码记入历史；mustChange 表示下次登录后必须修改密码
+func (h *Handler) setPassword(tx *gorm.DB, user *models.User, newPassword string, mustChange bool) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" {
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, PasswordHash: user.Password}).Error; err != nil {
+			return err
+		}
+
+		// 只保留策略需要的历史记录
+		keep := h.passwordPolicy().HistoryCount
+		if err := tx.Where("user_id = ? AND id NOT IN (?)", user.ID,
+			tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", user.ID).Order("id desc").Limit(keep),
+		).Delete(&models.PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+
+	updates := map[string]interface{}{
+		"password":             string(hashedPassword),
+		"must_change_password": mustChange,
+	}
+	return tx.Model(user).Updates(updates).Error
+}
+
+// createPasswordResetToken 创建一次性密码重置令牌，返回明文令牌
+func createPasswordResetToken(tx *gorm.DB, userID uint, ttl time.Duration) (string, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// passwordResetLink 生成前端的密码重置页面地址
+func (h *Handler) passwordResetLink(token string) string {
+	return strings.TrimRight(h.cfg.PublicURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+}
+
+// ForgotPassword 向用户邮箱发送密码重置链接；无论账户是否存在都返回相同的响应
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// 查找用户和发送邮件在后台进行，避免通过响应时间判断账户是否存在
+	go h.sendPasswordResetMail(strings.TrimSpace(req.Login))
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果账户存在且已绑定邮箱，重置链接将发送到该邮箱"})
+}
+
+// sendPasswordResetMail 为用户创建重置令牌并发送邮件
+func (h *Handler) sendPasswordResetMail(login string) {
+	var user models.User
+	if err := h.findUserByLogin(login, &user); err != nil {
+		return
+	}
+	if user.Email == nil || *user.Email == "" || user.Source != models.UserSourceLocal || user.IsServiceAccount() || user.IsDisabled(time.Now()) {
+		return
+	}
+
+	// 限制发送频率，避免被用来向用户邮箱发送大量邮件
+	var recent int64
+	h.db.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-forgotPasswordInterval)).
+		Count(&recent)
+	if recent > 0 {
+		return
+	}
+
+	token, err := createPasswordResetToken(h.db, user.ID, forgotPasswordTTL)
+	if err != nil {
+		log.Printf("Failed to create password reset token: %v", err)
+		return
+	}
+
+	systemName := models.GetOptionValue(h.db, models.OptionSystemName)
+	msg := mailer.Message{
+		To:      *user.Email,
+		Subject: systemName + " 密码重置",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置您账户密码的请求。请在 %d 分钟内打开以下链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会被修改。\n",
+			user.Username, int(forgotPasswordTTL.Minutes()), h.passwordResetLink(token)),
+	}
+	if err := h.mailer.Send(msg); err != nil {
+		log.Printf("Failed to send password reset mail: %v", err)
+	}
+}
+
+// ResetPassword 使用一次性重置令牌设置新密码
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	var record models.PasswordResetToken
+	err := h.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), time.Now()).
+		First(&record).Error
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重置链接无效或已过期"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, record.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重置链接无效或已过期"})
+		return
+	}
+
+	if err := h.validatePassword(&user, req.NewPassword); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证令牌只能使用一次
+		now := time.Now()
+		result := tx.Model(&record).Where("used_at IS NULL").Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidResetToken
+		}
+		// 同一用户此前申请的其他重置链接一并作废
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", record.UserID).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+		if err := h.setPassword(tx, &user, req.NewPassword, false); err != nil {
+			return err
+		}
+		return revokeSessions(tx, "user_id = ?", user.ID)
+	})
+	if errors.Is(err, errInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "重置链接无效或已过期"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+	h.recordAccountEvent(c, &user, models.SecurityEventPasswordReset, "通过重置链接")
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码重置成功，请使用新密码登录"})
+}
+
+// respondPasswordError 返回密码策略校验结果，列出全部未通过的规则
+func respondPasswordError(c *gin.Context, err error) {
+	var policyErr *password.PolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "密码不符合安全策略",
+			"violations": policyErr.Violations,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "密码校验失败"})
+}
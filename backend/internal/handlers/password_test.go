@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+// createResetToken 直接保存重置令牌，token 为邮件链接中的明文令牌
+func createResetToken(t *testing.T, s *testServer, userID uint, token string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(token))
+	record := models.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hex.EncodeToString(sum[:]),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := s.db.Create(&record).Error; err != nil {
+		t.Fatalf("create reset token: %v", err)
+	}
+}
+
+func TestResetPasswordInvalidatesOtherTokens(t *testing.T) {
+	s := newTestServer(t, nil)
+	user := s.createUser(t, "bob")
+	other := s.createUser(t, "carol")
+	createResetToken(t, s, user.ID, "first-link")
+	createResetToken(t, s, user.ID, "second-link")
+	createResetToken(t, s, other.ID, "carol-link")
+
+	newPassword := "Qw-12345!asd"
+	rec := s.do(t, http.MethodPost, "/api/password/reset", models.ResetPasswordRequest{Token: "second-link", NewPassword: newPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// 用过的和此前申请的链接都不能再使用
+	for _, token := range []string{"second-link", "first-link"} {
+		rec := s.do(t, http.MethodPost, "/api/password/reset", models.ResetPasswordRequest{Token: token, NewPassword: "Er-55555!zxc"})
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("reset with %s: status %d", token, rec.Code)
+		}
+	}
+
+	rec = s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: "bob", Password: newPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login with new password: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// 其他用户的链接不受影响
+	var unused int64
+	s.db.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", other.ID).Count(&unused)
+	if unused != 1 {
+		t.Fatalf("other user's reset token should stay valid, %d unused", unused)
+	}
+}
+
+func TestAdminResetGeneratesPolicyCompliantPassword(t *testing.T) {
+	s := newTestServer(t, nil)
+	operator := s.createUser(t, "bob")
+	if _, err := models.AssignRole(s.db, operator.ID, models.RoleAdmin, true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	target := s.createUser(t, "carol")
+	if err := s.db.Model(&models.Option{}).Where("option_name = ?", models.OptionPasswordMinLength).
+		Update("option_value", "30").Error; err != nil {
+		t.Fatalf("update option: %v", err)
+	}
+
+	rec := s.do(t, http.MethodPost, fmt.Sprintf("/api/admin/users/%d/reset-password", target.ID), nil,
+		"Authorization", "Bearer "+s.login(t, "bob"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	temporary, _ := decode(t, rec)["password"].(string)
+	if len(temporary) < 30 {
+		t.Fatalf("temporary password %q is shorter than the policy minimum", temporary)
+	}
+
+	rec = s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: "carol", Password: temporary})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login with temporary password: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if user := decode(t, rec)["user"].(map[string]interface{}); user["must_change_password"] != true {
+		t.Fatalf("temporary password must be changed after login: %v", user)
+	}
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"backend/internal/models"
+	"backend/internal/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// policyMethods 策略可以指定的请求方法
+var policyMethods = []string{
+	models.PolicyMethodAny, http.MethodGet, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// policyManagementPrefix 管理接口策略的路由前缀，修改策略时不能拒绝当前用户访问这些路由
+const policyManagementPrefix = "/api/admin/policies"
+
+// SetRoutes 设置获取已注册路由的函数，路由注册完成前无法在创建 Handler 时传入
+func (h *Handler) SetRoutes(routes func() gin.RoutesInfo) {
+	h.routes = routes
+}
+
+// registeredRoutes 返回所有已注册的路由，按路径和方法排序
+func (h *Handler) registeredRoutes() gin.RoutesInfo {
+	if h.routes == nil {
+		return nil
+	}
+	routes := h.routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ListRoutes 列出所有已注册的路由，供管理员编写策略
+func (h *Handler) ListRoutes(c *gin.Context) {
+	routes := h.registeredRoutes()
+	response := make([]models.RouteResponse, 0, len(routes))
+	for _, route := range routes {
+		response = append(response, models.RouteResponse{
+			Method:  route.Method,
+			Path:    route.Path,
+			Handler: route.Handler,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// policyResponses 补充策略对象的角色名和用户名
+func (h *Handler) policyResponses(policies []models.Policy) ([]models.PolicyResponse, error) {
+	roleIDs, userIDs := []uint{}, []uint{}
+	for _, p := range policies {
+		if p.RoleID != nil {
+			roleIDs = append(roleIDs, *p.RoleID)
+		}
+		if p.UserID != nil {
+			userIDs = append(userIDs, *p.UserID)
+		}
+	}
+
+	var roles []models.Role
+	if err := h.db.Where("id IN ?", roleIDs).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	roleNames := make(map[uint]string, len(roles))
+	for _, role := range roles {
+		roleNames[role.ID] = role.Name
+	}
+
+	var users []models.User
+	if err := h.db.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	response := make([]models.PolicyResponse, 0, len(policies))
+	for _, p := range policies {
+		item := models.PolicyResponse{
+			ID:          p.ID,
+			Subject:     p.Subject,
+			RoleID:      p.RoleID,
+			UserID:      p.UserID,
+			Method:      p.Method,
+			Path:        p.Path,
+			Effect:      p.Effect,
+			Description: p.Description,
+			CreatedAt:   p.CreatedAt,
+			UpdatedAt:   p.UpdatedAt,
+		}
+		if p.RoleID != nil {
+			item.RoleName = roleNames[*p.RoleID]
+		}
+		if p.UserID != nil {
+			item.Username = usernames[*p.UserID]
+		}
+		response = append(response, item)
+	}
+	return response, nil
+}
+
+// ListPolicies 列出所有接口策略，可按 subject、role_id、user_id 过滤
+func (h *Handler) ListPolicies(c *gin.Context) {
+	query := h.db.Order("id")
+	if subject := c.Query("subject"); subject != "" {
+		query = query.Where("subject = ?", subject)
+	}
+	for _, param := range []string{"role_id", "user_id"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的过滤条件"})
+			return
+		}
+		query = query.Where(param+" = ?", uint(id))
+	}
+
+	var policies []models.Policy
+	if err := query.Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问策略失败"})
+		return
+	}
+	response, err := h.policyResponses(policies)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// bindPolicyRequest 解析并校验策略，写入 p；失败时已写入响应
+func (h *Handler) bindPolicyRequest(c *gin.Context, p *models.Policy) bool {
+	var req models.PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return false
+	}
+
+	if req.Effect != models.PolicyAllow && req.Effect != models.PolicyDeny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的策略效果"})
+		return false
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+	validMethod := false
+	for _, m := range policyMethods {
+		if method == m {
+			validMethod = true
+			break
+		}
+	}
+	if !validMethod {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求方法"})
+		return false
+	}
+
+	path := strings.TrimSpace(req.Path)
+	if !h.validPolicyPath(path) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "路由不存在，请使用已注册的路由、以 /* 结尾的前缀或 *"})
+		return false
+	}
+
+	p.Subject = req.Subject
+	p.RoleID, p.UserID = nil, nil
+	switch req.Subject {
+	case models.PolicySubjectAll:
+	case models.PolicySubjectRole:
+		if req.RoleID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请指定角色"})
+			return false
+		}
+		var role models.Role
+		if err := h.db.First(&role, *req.RoleID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+			return false
+		}
+		p.RoleID = &role.ID
+	case models.PolicySubjectUser:
+		if req.UserID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请指定用户"})
+			return false
+		}
+		var user models.User
+		if err := h.db.First(&user, *req.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return false
+		}
+		p.UserID = &user.ID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的策略对象"})
+		return false
+	}
+
+	p.Method = method
+	p.Path = path
+	p.Effect = req.Effect
+	p.Description = strings.TrimSpace(req.Description)
+	return true
+}
+
+// validPolicyPath 策略路径必须是 *、以 /* 结尾的前缀或已注册的路由
+func (h *Handler) validPolicyPath(path string) bool {
+	if path == "*" || (strings.HasPrefix(path, "/") && strings.HasSuffix(path, "/*")) {
+		return true
+	}
+	for _, route := range h.registeredRoutes() {
+		if route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// policiesKeepAccess 检查修改后的策略是否仍允许当前用户管理策略，避免把自己锁在外面；不允许时已写入响应
+func (h *Handler) policiesKeepAccess(c *gin.Context, apply func([]models.Policy) []models.Policy) bool {
+	var policies []models.Policy
+	if err := h.db.Order("id").Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问策略失败"})
+		return false
+	}
+	policies = apply(policies)
+
+	value, _ := c.Get("user")
+	user := value.(*models.User)
+	for _, route := range h.registeredRoutes() {
+		if !strings.HasPrefix(route.Path, policyManagementPrefix) {
+			continue
+		}
+		if policy.Evaluate(policies, user, route.Method, route.Path).Decision == policy.DecisionDeny {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "修改后当前用户将无法访问 " + route.Method + " " + route.Path})
+			return false
+		}
+	}
+	return true
+}
+
+// findPolicy 按路径参数查找策略，失败时已写入响应
+func (h *Handler) findPolicy(c *gin.Context) (*models.Policy, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的策略ID"})
+		return nil, false
+	}
+
+	var p models.Policy
+	if err := h.db.First(&p, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "访问策略不存在"})
+		return nil, false
+	}
+	return &p, true
+}
+
+// respondPolicy 返回单条策略
+func (h *Handler) respondPolicy(c *gin.Context, message string, p models.Policy) {
+	response, err := h.policyResponses([]models.Policy{p})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问策略失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"policy":  response[0],
+	})
+}
+
+// CreatePolicy 添加接口策略
+func (h *Handler) CreatePolicy(c *gin.Context) {
+	var p models.Policy
+	if !h.bindPolicyRequest(c, &p) {
+		return
+	}
+
+	if !h.policiesKeepAccess(c, func(policies []models.Policy) []models.Policy {
+		return append(policies, p)
+	}) {
+		return
+	}
+
+	if err := h.db.Create(&p).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加访问策略失败"})
+		return
+	}
+	h.policies.Invalidate()
+
+	h.respondPolicy(c, "访问策略已添加", p)
+}
+
+// UpdatePolicy 修改接口策略
+func (h *Handler) UpdatePolicy(c *gin.Context) {
+	p, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+	if !h.bindPolicyRequest(c, p) {
+		return
+	}
+
+	if !h.policiesKeepAccess(c, func(policies []models.Policy) []models.Policy {
+		for i := range policies {
+			if policies[i].ID == p.ID {
+				policies[i] = *p
+			}
+		}
+		return policies
+	}) {
+		return
+	}
+
+	if err := h.db.Save(p).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改访问策略失败"})
+		return
+	}
+	h.policies.Invalidate()
+
+	h.respondPolicy(c, "访问策略已修改", *p)
+}
+
+// DeletePolicy 删除接口策略
+func (h *Handler) DeletePolicy(c *gin.Context) {
+	p, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+
+	if !h.policiesKeepAccess(c, func(policies []models.Policy) []models.Policy {
+		remaining := policies[:0]
+		for _, r := range policies {
+			if r.ID != p.ID {
+				remaining = append(remaining, r)
+			}
+		}
+		return remaining
+	}) {
+		return
+	}
+
+	if err := h.db.Delete(p).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除访问策略失败"})
+		return
+	}
+	h.policies.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "访问策略已删除"})
+}
+
+// ExplainPolicy 说明用户（默认为当前用户）以 method 访问 path 时命中的策略和结果，
+// path 可以是实际请求的路径或已注册的路由
+func (h *Handler) ExplainPolicy(c *gin.Context) {
+	path := strings.TrimSpace(c.Query("path"))
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定路径"})
+		return
+	}
+	method := strings.ToUpper(c.DefaultQuery("method", http.MethodGet))
+
+	value, _ := c.Get("user")
+	user := *value.(*models.User)
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		user = models.User{}
+		if err := h.db.First(&user, uint(id)).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		if err := models.LoadUserRoles(h.db, &user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
+			return
+		}
+	}
+
+	routes := []string{}
+	for _, route := range h.registeredRoutes() {
+		if route.Method == method {
+			routes = append(routes, route.Path)
+		}
+	}
+	route, ok := policy.ResolveRoute(routes, path)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有与该方法和路径匹配的路由"})
+		return
+	}
+
+	result, err := h.policies.Check(&user, method, route)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查访问策略失败"})
+		return
+	}
+	matched, err := h.policyResponses(result.Matched)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取访问策略失败"})
+		return
+	}
+
+	explanation := models.PolicyExplanation{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    emptyIfNil(user.Roles),
+		Method:   method,
+		Path:     path,
+		Route:    route,
+		Decision: result.Decision,
+		Matched:  matched,
+	}
+	switch result.Decision {
+	case policy.DecisionDeny:
+		explanation.Explanation = fmt.Sprintf("策略 #%d 拒绝访问，deny 策略优先于 allow 策略生效", result.Policy.ID)
+	case policy.DecisionAllow:
+		explanation.Explanation = fmt.Sprintf("策略 #%d 允许访问，不再检查角色权限", result.Policy.ID)
+	default:
+		explanation.Explanation = "未命中任何策略，按角色权限判断"
+	}
+	for i := range matched {
+		if result.Policy != nil && matched[i].ID == result.Policy.ID {
+			explanation.DecidedBy = &matched[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, explanation)
+}
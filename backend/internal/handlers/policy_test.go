@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"backend/internal/models"
+)
+
+func TestAllowPolicyGrantsAdminRoute(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+	user := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, user...); rec.Code != http.StatusForbidden {
+		t.Fatalf("without policy: status %d", rec.Code)
+	}
+
+	rec := s.do(t, http.MethodPost, "/api/admin/policies", models.PolicyRequest{
+		Subject: models.PolicySubjectUser, UserID: &bob.ID, Method: "GET", Path: "/api/admin/users", Effect: models.PolicyAllow,
+	}, admin...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create policy: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	id := uint(decode(t, rec)["policy"].(map[string]interface{})["id"].(float64))
+
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, user...); rec.Code != http.StatusOK {
+		t.Fatalf("allowed route: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	// 只放行策略指定的方法和路由
+	if rec := s.do(t, http.MethodGet, "/api/admin/roles", nil, user...); rec.Code != http.StatusForbidden {
+		t.Fatalf("other admin route: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodPost, "/api/admin/users", nil, user...); rec.Code != http.StatusForbidden {
+		t.Fatalf("other method: status %d", rec.Code)
+	}
+
+	if rec := s.do(t, http.MethodDelete, fmt.Sprintf("/api/admin/policies/%d", id), nil, admin...); rec.Code != http.StatusOK {
+		t.Fatalf("delete policy: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, user...); rec.Code != http.StatusForbidden {
+		t.Fatalf("deleted policy must stop granting access: status %d", rec.Code)
+	}
+}
+
+func TestDenyPolicyOverridesPermissions(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	carol := s.createAdmin(t, "carol")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+	other := []string{"Authorization", "Bearer " + s.login(t, "carol")}
+
+	rec := s.do(t, http.MethodPost, "/api/admin/policies", models.PolicyRequest{
+		Subject: models.PolicySubjectUser, UserID: &carol.ID, Method: "*", Path: "/api/admin/users/*", Effect: models.PolicyDeny,
+	}, admin...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create policy: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	id := decode(t, rec)["policy"].(map[string]interface{})["id"]
+
+	rec = s.do(t, http.MethodGet, fmt.Sprintf("/api/admin/users/%d/sessions", carol.ID), nil, other...)
+	if rec.Code != http.StatusForbidden || decode(t, rec)["policy_id"] != id {
+		t.Fatalf("denied route: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, other...); rec.Code != http.StatusOK {
+		t.Fatalf("route outside the prefix: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodGet, fmt.Sprintf("/api/admin/users/%d/sessions", carol.ID), nil, admin...); rec.Code != http.StatusOK {
+		t.Fatalf("other admin: status %d", rec.Code)
+	}
+}
+
+func TestPolicyCannotLockOutCurrentUser(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createAdmin(t, "alice")
+	admin := []string{"Authorization", "Bearer " + s.login(t, "alice")}
+
+	rec := s.do(t, http.MethodPost, "/api/admin/policies", models.PolicyRequest{
+		Subject: models.PolicySubjectAll, Method: "*", Path: "*", Effect: models.PolicyDeny,
+	}, admin...)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("policy denying the current user: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	rec = s.do(t, http.MethodPost, "/api/admin/policies", models.PolicyRequest{
+		Subject: models.PolicySubjectAll, Method: "GET", Path: "/api/admin/not-a-route", Effect: models.PolicyDeny,
+	}, admin...)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown route: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPermissions 列出所有可分配给角色的权限
+func (h *Handler) ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AllPermissions)
+}
+
+// ListRoles 列出所有角色及其权限和用户数
+func (h *Handler) ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := h.db.Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
+		return
+	}
+
+	var permissions []models.RolePermission
+	if err := h.db.Order("permission").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
+		return
+	}
+	byRole := map[uint][]string{}
+	for _, p := range permissions {
+		byRole[p.RoleID] = append(byRole[p.RoleID], p.Permission)
+	}
+
+	var counts []struct {
+		RoleID uint
+		Count  int64
+	}
+	if err := h.db.Model(&models.UserRole{}).Select("role_id, COUNT(*) AS count").Group("role_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
+		return
+	}
+	userCounts := map[uint]int64{}
+	for _, count := range counts {
+		userCounts[count.RoleID] = count.Count
+	}
+
+	response := make([]models.RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		response = append(response, toRoleResponse(role, byRole[role.ID], userCounts[role.ID]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func toRoleResponse(role models.Role, permissions []string, userCount int64) models.RoleResponse {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return models.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		BuiltIn:     role.BuiltIn,
+		Permissions: permissions,
+		UserCount:   userCount,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+// bindRoleRequest 校验角色名和权限，权限去重；只能授予自己拥有的权限，避免借助角色提升权限。失败时已写入响应
+func (h *Handler) bindRoleRequest(c *gin.Context) (*models.RoleRequest, bool) {
+	var req models.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写角色名称"})
+		return nil, false
+	}
+
+	value, _ := c.Get("user")
+	actor := value.(*models.User)
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, permission := range req.Permissions {
+		// 全部权限只属于内置的 admin 角色
+		if permission == models.PermissionAll || !models.ValidPermission(permission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限: " + permission})
+			return nil, false
+		}
+		if !actor.HasPermission(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "不能授予自己没有的权限: " + permission})
+			return nil, false
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	req.Permissions = permissions
+	return &req, true
+}
+
+// findRole 按路径参数查找角色，找不到时已写入响应
+func (h *Handler) findRole(c *gin.Context) (*models.Role, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return nil, false
+	}
+
+	var role models.Role
+	if err := h.db.First(&role, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return nil, false
+	}
+	return &role, true
+}
+
+// CreateRole 创建角色
+func (h *Handler) CreateRole(c *gin.Context) {
+	req, ok := h.bindRoleRequest(c)
+	if !ok {
+		return
+	}
+
+	role := models.Role{Name: req.Name, Description: req.Description}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, req.Permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色名称已存在"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "角色创建成功",
+		"role":    toRoleResponse(role, req.Permissions, 0),
+	})
+}
+
+// UpdateRole 修改角色的名称、说明和权限，内置角色不能修改
+func (h *Handler) UpdateRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	if role.BuiltIn {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改内置角色"})
+		return
+	}
+	if !h.canManageRoles(c, []models.Role{*role}) {
+		return
+	}
+
+	req, ok := h.bindRoleRequest(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Updates(map[string]interface{}{
+			"name":        req.Name,
+			"description": req.Description,
+		}).Error; err != nil {
+			return err
+		}
+		return setRolePermissions(tx, role.ID, req.Permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "角色名称已存在"})
+		return
+	}
+
+	var userCount int64
+	h.db.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Count(&userCount)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "角色更新成功",
+		"role":    toRoleResponse(*role, req.Permissions, userCount),
+	})
+}
+
+// DeleteRole 删除角色并取消所有用户的该角色，内置角色不能删除
+func (h *Handler) DeleteRole(c *gin.Context) {
+	role, ok := h.findRole(c)
+	if !ok {
+		return
+	}
+	if role.BuiltIn {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除内置角色"})
+		return
+	}
+	if !h.canManageRoles(c, []models.Role{*role}) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.Policy{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
+		return
+	}
+	h.policies.Invalidate()
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色删除成功"})
+}
+
+// SetUserRoles 用给定的角色替换用户当前的全部角色
+func (h *Handler) SetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	value, _ := c.Get("user")
+	if user.Username == "admin" || user.ID == value.(*models.User).ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改admin用户或自己的角色"})
+		return
+	}
+
+	var req models.SetUserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	roles, ok := h.findRoles(c, req.RoleIDs)
+	if !ok {
+		return
+	}
+
+	var current []models.Role
+	if err := h.db.Where("id IN (?)", h.db.Model(&models.UserRole{}).Select("role_id").Where("user_id = ?", user.ID)).
+		Find(&current).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
+		return
+	}
+
+	// 新增和移除的角色都必须在操作者的权限范围内
+	added, removed := diffRoles(current, roles)
+	if !h.canManageRoles(c, append(append([]models.Role{}, added...), removed...)) {
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户角色失败"})
+		return
+	}
+
+	details := []string{}
+	for _, role := range added {
+		details = append(details, models.RoleChangeDetail(role.Name, true))
+	}
+	for _, role := range removed {
+		details = append(details, models.RoleChangeDetail(role.Name, false))
+	}
+	if len(details) > 0 {
+		h.recordAccountEvent(c, &user, models.SecurityEventRoleChanged, strings.Join(details, "；"))
+	}
+
+	if err := models.LoadUserRoles(h.db, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户角色失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "用户角色已更新",
+		"user":    toUserResponse(&user),
+	})
+}
+
+// findRoles 按ID查找角色并去重，有不存在的角色时已写入响应
+func (h *Handler) findRoles(c *gin.Context, ids []uint) ([]models.Role, bool) {
+	roles := []models.Role{}
+	if len(ids) == 0 {
+		return roles, true
+	}
+	if err := h.db.Where("id IN ?", ids).Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色失败"})
+		return nil, false
+	}
+
+	found := map[uint]bool{}
+	for _, role := range roles {
+		found[role.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "角色不存在: " + strconv.FormatUint(uint64(id), 10)})
+			return nil, false
+		}
+	}
+	return roles, true
+}
+
+// canManageRoles 只有拥有角色的全部权限才能修改、删除或分配该角色，不允许时已写入响应
+func (h *Handler) canManageRoles(c *gin.Context, roles []models.Role) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	ids := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+
+	var permissions []models.RolePermission
+	if err := h.db.Where("role_id IN ?", ids).Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色权限失败"})
+		return false
+	}
+
+	value, _ := c.Get("user")
+	actor := value.(*models.User)
+	for _, p := range permissions {
+		if !actor.HasPermission(p.Permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "不能管理权限超出自己的角色"})
+			return false
+		}
+	}
+	return true
+}
+
+// rejectUnmanageableUser 目标用户拥有操作者没有的权限时拒绝，避免通过重置密码、修改邮箱等操作接管权限更高的账户；
+// 会填充目标用户的角色，拦截时返回 true
+func (h *Handler) rejectUnmanageableUser(c *gin.Context, target *models.User) bool {
+	if err := models.LoadUserRoles(h.db, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return true
+	}
+
+	value, _ := c.Get("user")
+	actor := value.(*models.User)
+	for _, permission := range target.Permissions {
+		if !actor.HasPermission(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "不能管理权限超出自己的用户"})
+			return true
+		}
+	}
+	return false
+}
+
+// setRolePermissions 用给定的权限替换角色当前的全部权限
+func setRolePermissions(tx *gorm.DB, roleID uint, permissions []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if err := tx.Create(&models.RolePermission{RoleID: roleID, Permission: permission}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffRoles(current, next []models.Role) (added, removed []models.Role) {
+	currentIDs := map[uint]bool{}
+	for _, role := range current {
+		currentIDs[role.ID] = true
+	}
+	nextIDs := map[uint]bool{}
+	for _, role := range next {
+		nextIDs[role.ID] = true
+		if !currentIDs[role.ID] {
+			added = append(added, role)
+		}
+	}
+	for _, role := range current {
+		if !nextIDs[role.ID] {
+			removed = append(removed, role)
+		}
+	}
+	return added, removed
+}
+
+// emptyIfNil 让 JSON 中输出 [] 而不是 null
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
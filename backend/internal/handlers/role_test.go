@@ -0,0 +1,104 @@
+package handlers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"backend/internal/models"
+)
+
+func TestAdminRoutesRequirePermissions(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	s.createOperator(t, "helpdesk", models.PermissionUsersRead)
+	user := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+	helpdesk := []string{"Authorization", "Bearer " + s.login(t, "helpdesk")}
+
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, user...); rec.Code != http.StatusForbidden {
+		t.Fatalf("user without permissions: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/admin/users", nil, helpdesk...); rec.Code != http.StatusOK {
+		t.Fatalf("granted permission: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	for _, tt := range []struct {
+		method, target, permission string
+	}{
+		{http.MethodPost, "/api/admin/users", models.PermissionUsersWrite},
+		{http.MethodGet, "/api/admin/roles", models.PermissionRolesRead},
+		{http.MethodPut, "/api/admin/options", models.PermissionSettingsWrite},
+		{http.MethodPost, "/api/admin/policies", models.PermissionAll},
+	} {
+		rec := s.do(t, tt.method, tt.target, nil, helpdesk...)
+		if rec.Code != http.StatusForbidden || decode(t, rec)["permission"] != tt.permission {
+			t.Errorf("%s %s: status %d, body %s", tt.method, tt.target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestOperatorCannotManageMorePrivilegedUsers(t *testing.T) {
+	s := newTestServer(t, nil)
+	admin := s.createAdmin(t, "alice")
+	bob := s.createUser(t, "bob")
+	s.createOperator(t, "helpdesk", models.PermissionUsersRead, models.PermissionUsersWrite)
+	helpdesk := []string{"Authorization", "Bearer " + s.login(t, "helpdesk")}
+
+	for _, tt := range []struct {
+		method, target string
+	}{
+		{http.MethodGet, "/api/admin/users/%d/sessions"},
+		{http.MethodDelete, "/api/admin/users/%d/sessions"},
+		{http.MethodPost, "/api/admin/users/%d/disable"},
+		{http.MethodDelete, "/api/admin/users/%d"},
+	} {
+		rec := s.do(t, tt.method, fmt.Sprintf(tt.target, admin.ID), nil, helpdesk...)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s on admin: status %d, body %s", tt.method, tt.target, rec.Code, rec.Body.String())
+		}
+	}
+
+	// 权限不超过操作者的用户可以管理
+	if rec := s.do(t, http.MethodGet, fmt.Sprintf("/api/admin/users/%d/sessions", bob.ID), nil, helpdesk...); rec.Code != http.StatusOK {
+		t.Fatalf("list sessions of a regular user: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodDelete, fmt.Sprintf("/api/admin/users/%d/sessions", bob.ID), nil, helpdesk...); rec.Code != http.StatusOK {
+		t.Fatalf("revoke sessions of a regular user: status %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	// 管理员的会话没有被吊销
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Authorization", "Bearer "+s.login(t, "alice")); rec.Code != http.StatusOK {
+		t.Fatalf("admin session: status %d", rec.Code)
+	}
+}
+
+func TestRolesCannotGrantMissingPermissions(t *testing.T) {
+	s := newTestServer(t, nil)
+	bob := s.createUser(t, "bob")
+	s.createOperator(t, "manager", models.PermissionRolesRead, models.PermissionRolesWrite, models.PermissionUsersRead)
+	manager := []string{"Authorization", "Bearer " + s.login(t, "manager")}
+
+	rec := s.do(t, http.MethodPost, "/api/admin/roles", models.RoleRequest{
+		Name: "operators", Permissions: []string{models.PermissionUsersRead, models.PermissionSettingsWrite},
+	}, manager...)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("role with a permission the operator lacks: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	rec = s.do(t, http.MethodPost, "/api/admin/roles", models.RoleRequest{Name: "readers", Permissions: []string{models.PermissionUsersRead}}, manager...)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("role within the operator's permissions: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	readers := uint(decode(t, rec)["role"].(map[string]interface{})["id"].(float64))
+
+	var adminRole models.Role
+	if err := s.db.Where("name = ?", models.RoleAdmin).First(&adminRole).Error; err != nil {
+		t.Fatalf("load admin role: %v", err)
+	}
+	target := fmt.Sprintf("/api/admin/users/%d/roles", bob.ID)
+	if rec := s.do(t, http.MethodPut, target, models.SetUserRolesRequest{RoleIDs: []uint{adminRole.ID}}, manager...); rec.Code != http.StatusForbidden {
+		t.Fatalf("assigning the admin role: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodPut, target, models.SetUserRolesRequest{RoleIDs: []uint{readers}}, manager...); rec.Code != http.StatusOK {
+		t.Fatalf("assigning a role within the operator's permissions: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
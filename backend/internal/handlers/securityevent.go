@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 安全事件列表的分页大小
+const (
+	defaultSecurityEventPageSize = 20
+	maxSecurityEventPageSize     = 100
+)
+
+// recordSecurityEvent 补充请求来源和操作者后保存安全事件；保存失败只记录日志，不影响请求本身
+func (h *Handler) recordSecurityEvent(c *gin.Context, event models.SecurityEvent) {
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+
+	// 模拟登录时实际操作者是发起模拟的管理员
+	actor, ok := c.Get("impersonator")
+	if !ok {
+		actor, ok = c.Get("user")
+	}
+	if ok {
+		actorID := actor.(*models.User).ID
+		if event.UserID == nil || *event.UserID != actorID {
+			event.ActorID = &actorID
+		}
+	}
+
+	if err := h.db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record security event %s: %v", event.Type, err)
+	}
+}
+
+// recordLoginEvent 记录一次登录尝试，failure 为空表示登录成功；user 为 nil 时只记录输入的用户名
+func (h *Handler) recordLoginEvent(c *gin.Context, user *models.User, username, method, failure string) {
+	event := models.SecurityEvent{
+		Username: username,
+		Type:     models.SecurityEventLoginSucceeded,
+		Method:   method,
+		Detail:   failure,
+	}
+	if failure != "" {
+		event.Type = models.SecurityEventLoginFailed
+	}
+	if user != nil {
+		event.UserID = &user.ID
+		event.Username = user.Username
+	}
+	h.recordSecurityEvent(c, event)
+
+	if user == nil || failure != "" {
+		return
+	}
+	// 不更新 updated_at，登录不算修改用户资料
+	now := time.Now()
+	ip := c.ClientIP()
+	err := h.db.Model(user).UpdateColumns(map[string]interface{}{
+		"last_login_at": now,
+		"last_login_ip": ip,
+	}).Error
+	if err != nil {
+		log.Printf("Failed to update last login of %s: %v", user.Username, err)
+		return
+	}
+	user.LastLoginAt = &now
+	user.LastLoginIP = ip
+}
+
+// recordAccountEvent 记录密码、两步验证、权限等账户安全相关的变更
+func (h *Handler) recordAccountEvent(c *gin.Context, user *models.User, eventType, detail string) {
+	h.recordSecurityEvent(c, models.SecurityEvent{
+		UserID:   &user.ID,
+		Username: user.Username,
+		Type:     eventType,
+		Detail:   detail,
+	})
+}
+
+// GetLoginHistory 获取当前用户的登录记录和安全事件
+func (h *Handler) GetLoginHistory(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	h.respondSecurityEvents(c, h.db.Where("user_id = ?", currentUser.ID))
+}
+
+// ListSecurityEvents 管理员查看所有用户的安全事件，可按用户、类型、IP 和时间范围过滤
+func (h *Handler) ListSecurityEvents(c *gin.Context) {
+	query := h.db
+	if userID := c.Query("user_id"); userID != "" {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+			return
+		}
+		query = query.Where("user_id = ?", uint(id))
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+
+	h.respondSecurityEvents(c, query)
+}
+
+// respondSecurityEvents 在 query 的基础上按类型、时间范围过滤，按时间倒序分页返回
+func (h *Handler) respondSecurityEvents(c *gin.Context, query *gorm.DB) {
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时间范围"})
+			return
+		}
+		query = query.Where(condition, t)
+	}
+
+	// 计数和分页查询复用同一组条件
+	query = query.Model(&models.SecurityEvent{}).Session(&gorm.Session{})
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultSecurityEventPageSize)))
+	if pageSize < 1 || pageSize > maxSecurityEventPageSize {
+		pageSize = defaultSecurityEventPageSize
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取安全事件失败"})
+		return
+	}
+
+	var events []models.SecurityEvent
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取安全事件失败"})
+		return
+	}
+
+	actorIDs := []uint{}
+	for _, event := range events {
+		if event.ActorID != nil {
+			actorIDs = append(actorIDs, *event.ActorID)
+		}
+	}
+	var actors []models.User
+	if err := h.db.Unscoped().Where("id IN ?", actorIDs).Find(&actors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取安全事件失败"})
+		return
+	}
+	actorNames := make(map[uint]string, len(actors))
+	for _, actor := range actors {
+		actorNames[actor.ID] = actor.Username
+	}
+
+	items := make([]models.SecurityEventResponse, 0, len(events))
+	for _, event := range events {
+		item := models.SecurityEventResponse{
+			ID:        event.ID,
+			UserID:    event.UserID,
+			Username:  event.Username,
+			Type:      event.Type,
+			Method:    event.Method,
+			Detail:    event.Detail,
+			IP:        event.IP,
+			UserAgent: event.UserAgent,
+			ActorID:   event.ActorID,
+			CreatedAt: event.CreatedAt,
+		}
+		if event.ActorID != nil {
+			item.ActorUsername = actorNames[*event.ActorID]
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, models.SecurityEventPage{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errUsernameTaken = errors.New("username taken")
+
+// serviceAccountRotationOverlap 轮换凭据后旧凭据的默认剩余有效期，留给调用方切换到新凭据
+const serviceAccountRotationOverlap = 24 * time.Hour
+
+// findServiceAccount 按路径参数查找服务账户并加载其角色，找不到或权限超出当前管理员时已写入响应；
+// 签发凭据时按服务账户的角色校验权限范围
+func (h *Handler) findServiceAccount(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return nil, false
+	}
+
+	var user models.User
+	if err := h.db.Where("account_type = ?", models.AccountTypeService).First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "服务账户不存在"})
+		return nil, false
+	}
+	// 能为服务账户签发凭据就等于拥有它的全部权限
+	if h.rejectUnmanageableUser(c, &user) {
+		return nil, false
+	}
+	return &user, true
+}
+
+// CreateServiceAccount 创建服务账户；服务账户没有可用的密码，只能通过 API 凭据访问
+func (h *Handler) CreateServiceAccount(c *gin.Context) {
+	var req models.CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写服务账户名称"})
+		return
+	}
+
+	hashedPassword, err := auth.UnusablePasswordHash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建服务账户失败"})
+		return
+	}
+
+	// 分配角色与 PUT /api/admin/users/:id/roles 的要求相同
+	value, _ := c.Get("user")
+	if len(req.RoleIDs) > 0 && !value.(*models.User).HasPermission(models.PermissionRolesWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有权限分配角色", "permission": models.PermissionRolesWrite})
+		return
+	}
+	roles, ok := h.findRoles(c, req.RoleIDs)
+	if !ok || !h.canManageRoles(c, roles) {
+		return
+	}
+
+	user := models.User{
+		Username:    name,
+		Password:    hashedPassword,
+		AccountType: models.AccountTypeService,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return errUsernameTaken
+		}
+		for _, role := range roles {
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: role.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return models.LoadUserRoles(tx, &user)
+	})
+	if errors.Is(err, errUsernameTaken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建服务账户失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "服务账户创建成功",
+		"user":    toUserResponse(&user),
+	})
+}
+
+// ListServiceAccountCredentials 列出服务账户的所有凭据，包含已吊销和已过期的凭据
+func (h *Handler) ListServiceAccountCredentials(c *gin.Context) {
+	user, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var tokens []models.APIToken
+	if err := h.db.Where("user_id = ?", user.ID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取凭据列表失败"})
+		return
+	}
+
+	response := make([]models.APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, toAPITokenResponse(token, user.Username))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateServiceAccountCredential 为服务账户签发新凭据，明文只在创建时返回一次
+func (h *Handler) CreateServiceAccountCredential(c *gin.Context) {
+	user, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	h.issueServiceAccountCredential(c, user, req.Name, req.Scopes, req.ExpiresAt, nil)
+}
+
+// RotateServiceAccountCredentials 签发新凭据，并把现有凭据的有效期缩短到重叠期结束，
+// 调用方可以在重叠期内切换到新凭据而不中断服务
+func (h *Handler) RotateServiceAccountCredentials(c *gin.Context) {
+	user, ok := h.findServiceAccount(c)
+	if !ok {
+		return
+	}
+
+	var req models.RotateServiceAccountCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	overlap := serviceAccountRotationOverlap
+	if req.Overlap != "" {
+		d, err := time.ParseDuration(req.Overlap)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的重叠时间"})
+			return
+		}
+		overlap = d
+	}
+
+	// 未指定名称和权限范围时沿用最近一个有效凭据
+	now := time.Now()
+	var active []models.APIToken
+	if err := h.db.Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", user.ID, now).
+		Order("created_at desc").Find(&active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "轮换凭据失败"})
+		return
+	}
+	name, scopes := req.Name, req.Scopes
+	if len(active) > 0 {
+		if name == "" {
+			name = active[0].Name
+		}
+		if len(scopes) == 0 {
+			scopes = active[0].ScopeList()
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定权限范围"})
+		return
+	}
+	if name == "" {
+		name = user.Username
+	}
+
+	h.issueServiceAccountCredential(c, user, name, scopes, req.ExpiresAt, func(tx *gorm.DB) error {
+		graceEnd := now.Add(overlap)
+		return tx.Model(&models.APIToken{}).
+			Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", user.ID, graceEnd).
+			Update("expires_at", graceEnd).Error
+	})
+}
+
+// issueServiceAccountCredential 校验参数并签发凭据，retire 不为空时在同一事务中处理旧凭据
+func (h *Handler) issueServiceAccountCredential(c *gin.Context, user *models.User, name string, scopes []string, expiresAt *time.Time, retire func(tx *gorm.DB) error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请填写凭据名称"})
+		return
+	}
+
+	scopes, message := validateAPITokenScopes(user, scopes)
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "过期时间必须晚于当前时间"})
+		return
+	}
+
+	var plaintext string
+	var token *models.APIToken
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if retire != nil {
+			if err := retire(tx); err != nil {
+				return err
+			}
+		}
+		var err error
+		plaintext, token, err = createAPIToken(tx, user.ID, name, scopes, expiresAt)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "签发凭据失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     plaintext,
+		"api_token": toAPITokenResponse(*token, user.Username),
+		"message":   "凭据已签发，请立即保存，之后将无法再次查看",
+	})
+}
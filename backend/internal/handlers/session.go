@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sessionTTL 按系统配置返回会话有效期，rememberMe 时使用“记住我”的有效期
+func (h *Handler) sessionTTL(rememberMe bool) time.Duration {
+	if rememberMe {
+		if hours := models.GetOptionInt(h.db, models.OptionRememberMeTTLHours, 0); hours > 0 {
+			return time.Duration(hours) * time.Hour
+		}
+	}
+	if hours := models.GetOptionInt(h.db, models.OptionSessionTTLHours, 0); hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return h.cfg.RefreshTokenTTL
+}
+
+// sessionIdleTimeout 会话允许的最长空闲时间，0 表示不限制
+func sessionIdleTimeout(db *gorm.DB) time.Duration {
+	return time.Duration(models.GetOptionInt(db, models.OptionSessionIdleTimeoutMinutes, 0)) * time.Minute
+}
+
+// startSession 创建新的登录会话并签发令牌
+func (h *Handler) startSession(c *gin.Context, user *models.User, rememberMe bool) (*tokenPair, error) {
+	sessionID, err := generateTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := h.sessionTTL(rememberMe)
+	var pair *tokenPair
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		session := models.Session{
+			SessionID:  sessionID,
+			UserID:     user.ID,
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(ttl),
+			RememberMe: rememberMe,
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = h.issueTokens(tx, user, sessionID, ttl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// revokeSessions 吊销查询命中的所有会话及其刷新令牌
+func revokeSessions(db *gorm.DB, query interface{}, args ...interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var sessions []models.Session
+		if err := tx.Where(query, args...).Where("revoked_at IS NULL").Find(&sessions).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, session := range sessions {
+			if err := tx.Model(&session).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			if err := revokeTokenFamily(tx, session.SessionID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (h *Handler) listActiveSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := h.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func toSessionResponses(sessions []models.Session, currentID string) []models.SessionResponse {
+	response := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, models.SessionResponse{
+			ID:         session.ID,
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.SessionID == currentID,
+
+			ImpersonatorID: session.ImpersonatorID,
+		})
+	}
+	return response
+}
+
+// currentSessionID 返回当前请求所属的会话标识
+func currentSessionID(c *gin.Context) string {
+	value, exists := c.Get("session")
+	if !exists {
+		return ""
+	}
+	return value.(*models.Session).SessionID
+}
+
+// ListSessions 列出当前用户的活跃会话
+func (h *Handler) ListSessions(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	sessions, err := h.listActiveSessions(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toSessionResponses(sessions, currentSessionID(c)))
+}
+
+// RevokeSession 吊销当前用户的指定会话
+func (h *Handler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var session models.Session
+	if err := h.db.Where("id = ? AND user_id = ?", uint(id), currentUser.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		return
+	}
+
+	if err := revokeSessions(h.db, "id = ?", session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "会话已吊销"})
+}
+
+// RevokeOtherSessions 吊销当前用户除本次会话外的所有会话
+func (h *Handler) RevokeOtherSessions(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	err := revokeSessions(h.db, "user_id = ? AND session_id <> ?", currentUser.ID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "其他会话已全部吊销"})
+}
+
+// ListUserSessions 管理员查看指定用户的活跃会话
+func (h *Handler) ListUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
+
+	sessions, err := h.listActiveSessions(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取会话列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toSessionResponses(sessions, currentSessionID(c)))
+}
+
+// RevokeUserSessions 管理员强制指定用户的所有会话下线
+func (h *Handler) RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
+
+	if err := revokeSessions(h.db, "user_id = ?", user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "吊销会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "用户已被强制下线"})
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/middleware"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// tokenPair 登录或刷新后返回给客户端的令牌
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64
+	SessionTTL   time.Duration // 刷新令牌的有效期，即会话有效期
+}
+
+// issueTokens 为用户签发访问令牌，并在会话对应的家族中创建有效期为 ttl 的刷新令牌
+func (h *Handler) issueTokens(tx *gorm.DB, user *models.User, sessionID string, ttl time.Duration) (*tokenPair, error) {
+	// 令牌中的角色仅供其他服务参考，本服务以数据库为准
+	if err := models.LoadUserRoles(tx, user); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := h.signAccessToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(h.cfg.AccessTokenTTL.Seconds()),
+		SessionTTL:   ttl,
+	}, nil
+}
+
+// tokenResponse 按配置的传递方式返回令牌；Cookie 模式下令牌写入 HttpOnly Cookie，响应体中不包含令牌
+func (h *Handler) tokenResponse(c *gin.Context, pair *tokenPair) (gin.H, error) {
+	if h.cfg.AuthMode == config.AuthModeCookie {
+		if err := middleware.SetAuthCookies(c, h.cfg, pair.AccessToken, pair.RefreshToken, pair.SessionTTL); err != nil {
+			return nil, err
+		}
+		return gin.H{"expires_in": pair.ExpiresIn}, nil
+	}
+	return gin.H{
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
+	}, nil
+}
+
+func (h *Handler) signAccessToken(user *models.User, sessionID string) (string, error) {
+	claims, err := h.accessTokenClaims(user, sessionID, time.Now().Add(h.cfg.AccessTokenTTL))
+	if err != nil {
+		return "", err
+	}
+	return h.keys.Sign(claims)
+}
+
+func (h *Handler) accessTokenClaims(user *models.User, sessionID string, expiresAt time.Time) (jwt.MapClaims, error) {
+	jti, err := generateTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.MapClaims{
+		"id":       user.ID,
+		"username": user.Username,
+		"is_admin": user.IsAdmin(),
+		"roles":    emptyIfNil(user.Roles),
+		"jti":      jti,
+		"sid":      sessionID,
+		"aud":      h.keys.Audience(),
+		"exp":      expiresAt.Unix(),
+	}, nil
+}
+
+// RefreshToken 使用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	// Cookie 模式下请求体中没有刷新令牌时从 Cookie 读取，此时需要校验 CSRF 令牌
+	if req.RefreshToken == "" && h.cfg.AuthMode == config.AuthModeCookie {
+		if cookie, err := c.Cookie(middleware.RefreshTokenCookie); err == nil && cookie != "" {
+			if !middleware.ValidCSRF(c) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "CSRF 校验失败"})
+				return
+			}
+			req.RefreshToken = cookie
+		}
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	var user models.User
+	var pair *tokenPair
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(req.RefreshToken)).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		if record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		// 仅当令牌尚未使用时才标记为已使用，避免并发请求同时轮换成功
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", record.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		// 刷新令牌家族即会话，长时间无操作的会话不能再刷新
+		var session models.Session
+		if err := tx.Where("session_id = ? AND revoked_at IS NULL", record.FamilyID).First(&session).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+		now = time.Now()
+		if session.IsIdle(sessionIdleTimeout(tx), now) {
+			return errInvalidRefreshToken
+		}
+
+		// 轮换时按会话登录时的选择顺延有效期
+		ttl := h.sessionTTL(session.RememberMe)
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"expires_at":   now.Add(ttl),
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+		// 到期的账户在清理任务停用前也不能继续刷新
+		if user.IsDisabled(now) {
+			return errInvalidRefreshToken
+		}
+
+		var err error
+		pair, err = h.issueTokens(tx, &user, record.FamilyID, ttl)
+		return err
+	})
+
+	if errors.Is(err, errRefreshTokenReused) {
+		// 已使用过的刷新令牌再次出现，说明令牌可能已泄露，吊销整个会话
+		h.revokeReusedFamily(req.RefreshToken)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "刷新令牌已失效"})
+		return
+	}
+	if errors.Is(err, errInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "刷新令牌已失效"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		return
+	}
+
+	response, err := h.tokenResponse(c, pair)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) revokeReusedFamily(refreshToken string) {
+	var record models.RefreshToken
+	if err := h.db.Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+		return
+	}
+	revokeSessions(h.db, "session_id = ?", record.FamilyID)
+}
+
+// revokeTokenFamily 吊销家族内所有尚未吊销的刷新令牌
+func revokeTokenFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// generateTokenID 生成随机的令牌唯一标识
+func generateTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generateOpaqueToken 生成不透明的随机令牌
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 计算令牌的 SHA-256 哈希，数据库中只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,116 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+// loginTokens 使用密码登录，返回访问令牌和刷新令牌
+func (s *testServer) loginTokens(t *testing.T, username string) (access, refresh string) {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: username, Password: testPassword})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	response := decode(t, rec)
+	access, _ = response["token"].(string)
+	refresh, _ = response["refresh_token"].(string)
+	if access == "" || refresh == "" {
+		t.Fatalf("login returned no tokens: %s", rec.Body.String())
+	}
+	return access, refresh
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	access, refresh := s.loginTokens(t, "bob")
+	other := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+	bearer := []string{"Authorization", "Bearer " + access}
+
+	if rec := s.do(t, http.MethodPost, "/api/logout", nil, bearer...); rec.Code != http.StatusOK {
+		t.Fatalf("logout: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, bearer...); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token after logout: status %d", rec.Code)
+	}
+	if rec := s.do(t, http.MethodPost, "/api/token/refresh", models.RefreshTokenRequest{RefreshToken: refresh}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("refresh token after logout: status %d", rec.Code)
+	}
+	// 只结束当前会话，其他设备上的登录不受影响
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, other...); rec.Code != http.StatusOK {
+		t.Fatalf("other session after logout: status %d", rec.Code)
+	}
+}
+
+// refresh 使用刷新令牌换取新令牌，成功时返回新的访问令牌和刷新令牌
+func (s *testServer) refresh(t *testing.T, refreshToken string) (int, string, string) {
+	t.Helper()
+	rec := s.do(t, http.MethodPost, "/api/token/refresh", models.RefreshTokenRequest{RefreshToken: refreshToken})
+	if rec.Code != http.StatusOK {
+		return rec.Code, "", ""
+	}
+	response := decode(t, rec)
+	access, _ := response["token"].(string)
+	next, _ := response["refresh_token"].(string)
+	return rec.Code, access, next
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	_, first := s.loginTokens(t, "bob")
+
+	code, access, second := s.refresh(t, first)
+	if code != http.StatusOK || second == "" || second == first {
+		t.Fatalf("refresh: status %d, rotated %v", code, second != first)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Authorization", "Bearer "+access); rec.Code != http.StatusOK {
+		t.Fatalf("refreshed access token: status %d", rec.Code)
+	}
+	if code, _, _ := s.refresh(t, second); code != http.StatusOK {
+		t.Fatalf("rotated refresh token: status %d", code)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	_, first := s.loginTokens(t, "bob")
+	other := []string{"Authorization", "Bearer " + s.login(t, "bob")}
+
+	_, access, second := s.refresh(t, first)
+	// 已轮换的令牌再次出现，视为泄露，整个会话被吊销
+	if code, _, _ := s.refresh(t, first); code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token: status %d", code)
+	}
+	if code, _, _ := s.refresh(t, second); code != http.StatusUnauthorized {
+		t.Fatalf("latest refresh token after reuse: status %d", code)
+	}
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, "Authorization", "Bearer "+access); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("access token after reuse: status %d", rec.Code)
+	}
+	// 其他会话不受影响
+	if rec := s.do(t, http.MethodGet, "/api/user", nil, other...); rec.Code != http.StatusOK {
+		t.Fatalf("other session after reuse: status %d", rec.Code)
+	}
+}
+
+func TestRefreshTokenRejectsUnknownAndDisabled(t *testing.T) {
+	s := newTestServer(t, nil)
+	bob := s.createUser(t, "bob")
+	_, refresh := s.loginTokens(t, "bob")
+
+	if code, _, _ := s.refresh(t, "not-a-refresh-token"); code != http.StatusUnauthorized {
+		t.Fatalf("unknown refresh token: status %d", code)
+	}
+	if err := s.db.Model(bob).Update("disabled_at", time.Now()).Error; err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if code, _, _ := s.refresh(t, refresh); code != http.StatusUnauthorized {
+		t.Fatalf("disabled user: status %d", code)
+	}
+}
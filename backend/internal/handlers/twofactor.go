@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/auth"
+	"backend/internal/models"
+	"backend/internal/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	challengePurpose2FA = "2fa"
+	challengeTokenTTL   = 5 * time.Minute
+	recoveryCodeCount   = 10
+)
+
+var errInvalidChallenge = errors.New("invalid challenge token")
+
+// signChallengeToken 签发两步验证的中间令牌，只能用于完成登录
+func (h *Handler) signChallengeToken(user *models.User, rememberMe bool) (string, error) {
+	jti, err := generateTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	return h.keys.Sign(jwt.MapClaims{
+		"id":          user.ID,
+		"purpose":     challengePurpose2FA,
+		"jti":         jti,
+		"exp":         time.Now().Add(challengeTokenTTL).Unix(),
+		"remember_me": rememberMe, // 第一步登录时的选择，完成验证后使用
+	})
+}
+
+// parseChallengeToken 校验中间令牌并返回其声明
+func (h *Handler) parseChallengeToken(tokenString string) (jwt.MapClaims, error) {
+	claims, err := h.keys.Parse(tokenString)
+	if err != nil || claims["purpose"] != challengePurpose2FA {
+		return nil, errInvalidChallenge
+	}
+
+	jti, _ := claims["jti"].(string)
+	var count int64
+	if err := h.db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if jti == "" || count > 0 {
+		return nil, errInvalidChallenge
+	}
+	return claims, nil
+}
+
+// verifyTOTP 校验验证码并记录时间步，同一验证码不能重复使用
+func (h *Handler) verifyTOTP(user *models.User, secret, code string) bool {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+
+	result := h.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// useRecoveryCode 消耗一个未使用的恢复码
+func (h *Handler) useRecoveryCode(user *models.User, code string) bool {
+	code = normalizeRecoveryCode(code)
+	if code == "" {
+		return false
+	}
+
+	result := h.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashToken(code)).
+		Update("used_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// verifySecondFactor 使用验证码或恢复码完成第二因素校验
+func (h *Handler) verifySecondFactor(user *models.User, code, recoveryCode string) bool {
+	if code != "" && h.verifyTOTP(user, user.TOTPSecret, code) {
+		return true
+	}
+	return h.useRecoveryCode(user, recoveryCode)
+}
+
+// generateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := generateTokenID()
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:10]
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashToken(normalizeRecoveryCode(code)),
+		}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// requiresTwoFactorSetup 判断管理员是否因强制策略而需要先启用两步验证
+func requiresTwoFactorSetup(db *gorm.DB, user *models.User) bool {
+	return user.IsAdmin() && !user.TOTPEnabled &&
+		models.GetOptionValue(db, models.OptionRequireAdmin2FA) == "true"
+}
+
+// VerifyTwoFactorLogin 登录第二步：校验中间令牌和验证码后签发正式令牌
+func (h *Handler) VerifyTwoFactorLogin(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	claims, err := h.parseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证已过期，请重新登录"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(claims["id"].(float64))).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证已过期，请重新登录"})
+		return
+	}
+
+	// 验证码同样受登录失败限制，防止穷举
+	wait, err := h.loginRetryAfter(user.Username, c.ClientIP())
+	if err != nil {
+		rejectThrottleUnavailable(c)
+		return
+	}
+	if wait > 0 {
+		h.recordLoginEvent(c, &user, user.Username, models.LoginMethodTOTP, "登录尝试过于频繁")
+		rejectThrottledLogin(c, wait)
+		return
+	}
+
+	if !h.verifySecondFactor(&user, req.Code, req.RecoveryCode) {
+		if err := h.recordLoginFailure(user.Username, c.ClientIP()); err != nil {
+			rejectThrottleUnavailable(c)
+			return
+		}
+		h.recordLoginEvent(c, &user, user.Username, models.LoginMethodTOTP, "两步验证码错误")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	// 中间令牌只能使用一次
+	exp, _ := claims.GetExpirationTime()
+	revoked := models.RevokedToken{JTI: claims["jti"].(string), ExpiresAt: exp.Time}
+	if err := h.db.Create(&revoked).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证已过期，请重新登录"})
+		return
+	}
+
+	h.clearLoginFailures(user.Username)
+	rememberMe, _ := claims["remember_me"].(bool)
+	h.completeLogin(c, &user, models.LoginMethodTOTP, rememberMe)
+}
+
+// GetTwoFactorStatus 获取当前用户的两步验证状态
+func (h *Handler) GetTwoFactorStatus(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, currentUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	var remaining int64
+	h.db.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", dbUser.ID).Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  dbUser.TOTPEnabled,
+		"required":                 requiresTwoFactorSetup(h.db, &dbUser),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// SetupTwoFactor 生成新的两步验证密钥，需确认后才会启用
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, currentUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	if dbUser.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "两步验证已启用"})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成密钥失败"})
+		return
+	}
+
+	if err := h.db.Model(&dbUser).Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存密钥失败"})
+		return
+	}
+
+	issuer := models.GetOptionValue(h.db, models.OptionSystemName)
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totp.URI(issuer, dbUser.Username, secret),
+	})
+}
+
+// EnableTwoFactor 使用验证码确认密钥并启用两步验证，返回恢复码
+func (h *Handler) EnableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, currentUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	if dbUser.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "两步验证已启用"})
+		return
+	}
+	if dbUser.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请先生成两步验证密钥"})
+		return
+	}
+
+	if !h.verifyTOTP(&dbUser, dbUser.TOTPSecret, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dbUser).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = generateRecoveryCodes(tx, dbUser.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+		return
+	}
+	h.recordAccountEvent(c, &dbUser, models.SecurityEventTwoFactorEnabled, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "两步验证已启用",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor 校验密码和验证码后关闭两步验证；LDAP 用户的密码由目录校验，
+// 单点登录用户没有可用的密码，只校验验证码或恢复码
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, currentUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	if !dbUser.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "两步验证未启用"})
+		return
+	}
+
+	if dbUser.Source != models.UserSourceOIDC {
+		authenticated, err := h.authenticators.Authenticate(c.Request.Context(), dbUser.Username, req.Password)
+		if errors.Is(err, auth.ErrUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "认证服务暂不可用，请稍后再试"})
+			return
+		}
+		if err != nil || authenticated.ID != dbUser.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
+			return
+		}
+	}
+
+	if !h.verifySecondFactor(&dbUser, req.Code, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	if err := disableTwoFactor(h.db, dbUser.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+	h.recordAccountEvent(c, &dbUser, models.SecurityEventTwoFactorDisabled, "")
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 校验验证码后重新生成恢复码
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(*models.User)
+
+	var dbUser models.User
+	if err := h.db.First(&dbUser, currentUser.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户信息失败"})
+		return
+	}
+
+	if !dbUser.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "两步验证未启用"})
+		return
+	}
+
+	if !h.verifyTOTP(&dbUser, dbUser.TOTPSecret, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "验证码错误"})
+		return
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = generateRecoveryCodes(tx, dbUser.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+	h.recordAccountEvent(c, &dbUser, models.SecurityEventRecoveryCodesRegenerated, "")
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor 管理员重置用户的两步验证
+func (h *Handler) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if h.rejectUnmanageableUser(c, &user) {
+		return
+	}
+
+	if err := disableTwoFactor(h.db, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置两步验证失败"})
+		return
+	}
+	h.recordAccountEvent(c, &user, models.SecurityEventTwoFactorDisabled, "管理员重置")
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已重置"})
+}
+
+func disableTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"totp_secret":  "",
+			"totp_enabled": false,
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
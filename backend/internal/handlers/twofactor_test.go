@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/auth/ldaptest"
+	"backend/internal/models"
+	"backend/internal/totp"
+)
+
+// enableTwoFactor 为已登录用户启用两步验证，返回恢复码
+func enableTwoFactor(t *testing.T, s *testServer, token string) []string {
+	t.Helper()
+	bearer := []string{"Authorization", "Bearer " + token}
+	rec := s.do(t, http.MethodPost, "/api/user/2fa/setup", nil, bearer...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("setup 2FA: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	code, err := totp.Code(decode(t, rec)["secret"].(string), totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	rec = s.do(t, http.MethodPost, "/api/user/2fa/enable", models.TwoFactorCodeRequest{Code: code}, bearer...)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("enable 2FA: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	codes := []string{}
+	for _, code := range decode(t, rec)["recovery_codes"].([]interface{}) {
+		codes = append(codes, code.(string))
+	}
+	return codes
+}
+
+func twoFactorEnabled(t *testing.T, s *testServer, username string) bool {
+	t.Helper()
+	var user models.User
+	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
+		t.Fatalf("load %s: %v", username, err)
+	}
+	return user.TOTPEnabled
+}
+
+func TestDisableTwoFactorLocalUser(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.createUser(t, "bob")
+	token := s.login(t, "bob")
+	codes := enableTwoFactor(t, s, token)
+	bearer := []string{"Authorization", "Bearer " + token}
+
+	for _, password := range []string{"", "wrong-password"} {
+		rec := s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Password: password, Code: codes[0]}, bearer...)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("disable with password %q: status %d", password, rec.Code)
+		}
+	}
+	rec := s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Password: testPassword, Code: "000000"}, bearer...)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("disable with a wrong code: status %d", rec.Code)
+	}
+
+	rec = s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Password: testPassword, Code: codes[0]}, bearer...)
+	if rec.Code != http.StatusOK || twoFactorEnabled(t, s, "bob") {
+		t.Fatalf("disable: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDisableTwoFactorOIDCUser(t *testing.T) {
+	s, _ := newOIDCTest(t, aliceClaims(), nil)
+	token := loginOIDC(t, s)["token"].(string)
+	codes := enableTwoFactor(t, s, token)
+	bearer := []string{"Authorization", "Bearer " + token}
+
+	rec := s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Code: "000000"}, bearer...)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("disable with a wrong code: status %d", rec.Code)
+	}
+	// 单点登录用户没有密码，只需验证码或恢复码
+	rec = s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Code: codes[0]}, bearer...)
+	if rec.Code != http.StatusOK || twoFactorEnabled(t, s, "alice") {
+		t.Fatalf("disable: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDisableTwoFactorLDAPUser(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pick port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	server, err := ldaptest.Start(addr, ldaptest.User{UID: "dave", Password: "dave-pass"})
+	if err != nil {
+		t.Fatalf("ldaptest.Start: %v", err)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	s := newTestServer(t, map[string]string{
+		"LDAP_URL":                "ldap://" + addr,
+		"LDAP_BIND_DN":            ldaptest.AdminDN,
+		"LDAP_BIND_PASSWORD":      ldaptest.AdminPassword,
+		"LDAP_BASE_DN":            ldaptest.BaseDN,
+		"LDAP_USER_FILTER":        "(uid=%s)",
+		"LDAP_USERNAME_ATTRIBUTE": "uid",
+	})
+	rec := s.do(t, http.MethodPost, "/api/login", models.LoginRequest{Username: "dave", Password: "dave-pass"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("LDAP login: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	token := decode(t, rec)["token"].(string)
+	codes := enableTwoFactor(t, s, token)
+	bearer := []string{"Authorization", "Bearer " + token}
+
+	rec = s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Password: "wrong-password", Code: codes[0]}, bearer...)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("disable with a wrong directory password: status %d", rec.Code)
+	}
+	// 目录密码通过 LDAP 校验，本地保存的不可用哈希不参与
+	rec = s.do(t, http.MethodPost, "/api/user/2fa/disable", models.DisableTwoFactorRequest{Password: "dave-pass", Code: codes[0]}, bearer...)
+	if rec.Code != http.StatusOK || twoFactorEnabled(t, s, "dave") {
+		t.Fatalf("disable: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,123 @@
+// Package ipfilter 按数据库中的 CIDR 规则限制请求来源，规则在进程内缓存，修改后需调用 Invalidate
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+
+	"backend/internal/models"
+	"backend/internal/snapshot"
+
+	"gorm.io/gorm"
+)
+
+// Denied 来源 IP 被某一作用范围的规则拒绝
+type Denied struct {
+	Scope string
+	Rule  *models.IPRule // 命中的 deny 规则，为 nil 表示不在该范围的允许列表中
+}
+
+func (d *Denied) Error() string {
+	if d.Rule != nil {
+		return fmt.Sprintf("matched %s deny rule %d (%s)", d.Scope, d.Rule.ID, d.Rule.CIDR)
+	}
+	return fmt.Sprintf("not in %s allowlist", d.Scope)
+}
+
+type rule struct {
+	models.IPRule
+	prefix netip.Prefix
+}
+
+// Filter 缓存全部访问规则
+type Filter struct {
+	rules *snapshot.Cache[[]rule]
+}
+
+// New 创建访问规则过滤器，规则在第一次检查时加载
+func New(db *gorm.DB) *Filter {
+	return &Filter{rules: snapshot.New(func() ([]rule, error) {
+		var records []models.IPRule
+		if err := db.Find(&records).Error; err != nil {
+			return nil, err
+		}
+		return compile(records), nil
+	})}
+}
+
+// ParsePrefix 解析 CIDR 或单个 IP，返回规范化的网段
+func ParsePrefix(value string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Invalidate 规则变更后清除缓存，下次检查时重新加载
+func (f *Filter) Invalidate() {
+	f.rules.Invalidate()
+}
+
+// compile 解析规则的网段，无法解析的规则被忽略（创建时已校验）
+func compile(records []models.IPRule) []rule {
+	rules := make([]rule, 0, len(records))
+	for _, record := range records {
+		prefix, err := ParsePrefix(record.CIDR)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, rule{IPRule: record, prefix: prefix})
+	}
+	return rules
+}
+
+// Check 检查来源 IP 能否通过指定作用范围的规则，scope 为 user 时只检查该用户的规则；
+// 被拒绝时返回 *Denied
+func (f *Filter) Check(ip, scope string, userID uint) error {
+	rules, err := f.rules.Get()
+	if err != nil {
+		return err
+	}
+	return check(rules, ip, scope, userID)
+}
+
+// Evaluate 使用给定的规则集检查，用于在保存规则前判断是否会拒绝当前请求
+func Evaluate(records []models.IPRule, ip, scope string, userID uint) error {
+	return check(compile(records), ip, scope, userID)
+}
+
+func check(rules []rule, ip, scope string, userID uint) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return &Denied{Scope: scope}
+	}
+	addr = addr.Unmap()
+
+	hasAllow, allowed := false, false
+	for i := range rules {
+		r := &rules[i]
+		if r.Scope != scope || (scope == models.IPRuleScopeUser && (r.UserID == nil || *r.UserID != userID)) {
+			continue
+		}
+		matched := r.prefix.Contains(addr)
+		switch r.Action {
+		case models.IPRuleDeny:
+			if matched {
+				record := r.IPRule
+				return &Denied{Scope: scope, Rule: &record}
+			}
+		case models.IPRuleAllow:
+			hasAllow = true
+			allowed = allowed || matched
+		}
+	}
+	if hasAllow && !allowed {
+		return &Denied{Scope: scope}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package ipfilter_test
+
+import (
+	"errors"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/ipfilter"
+	"backend/internal/models"
+)
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"192.0.2.7", "192.0.2.7/32"},
+		{"::ffff:192.0.2.7", "192.0.2.7/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+	}
+	for _, tt := range tests {
+		prefix, err := ipfilter.ParsePrefix(tt.value)
+		if err != nil || prefix.String() != tt.want {
+			t.Errorf("ParsePrefix(%q) = %v, %v, want %s", tt.value, prefix, err, tt.want)
+		}
+	}
+	if _, err := ipfilter.ParsePrefix("10.0.0.0/33"); err == nil {
+		t.Error("invalid CIDR should be rejected")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rules := []models.IPRule{
+		{ID: 1, Action: models.IPRuleAllow, CIDR: "10.0.0.0/8", Scope: models.IPRuleScopeAdmin},
+		{ID: 2, Action: models.IPRuleDeny, CIDR: "10.6.6.0/24", Scope: models.IPRuleScopeAdmin},
+		{ID: 3, Action: models.IPRuleDeny, CIDR: "203.0.113.0/24", Scope: models.IPRuleScopeGlobal},
+		{ID: 4, Action: models.IPRuleAllow, CIDR: "192.0.2.0/24", Scope: models.IPRuleScopeUser, UserID: uintPtr(7)},
+	}
+
+	tests := []struct {
+		ip, scope string
+		userID    uint
+		denied    bool
+		ruleID    uint // 命中的 deny 规则，0 表示不在允许列表中
+	}{
+		{"10.1.2.3", models.IPRuleScopeAdmin, 0, false, 0},
+		{"10.6.6.6", models.IPRuleScopeAdmin, 0, true, 2}, // deny 优先于 allow
+		{"198.51.100.1", models.IPRuleScopeAdmin, 0, true, 0},
+		{"::ffff:10.1.2.3", models.IPRuleScopeAdmin, 0, false, 0},
+		{"198.51.100.1", models.IPRuleScopeAuth, 0, false, 0}, // 其他作用范围的规则不生效
+		{"203.0.113.9", models.IPRuleScopeGlobal, 0, true, 3},
+		{"203.0.113.9", models.IPRuleScopeAdmin, 0, true, 0},
+		{"192.0.2.5", models.IPRuleScopeUser, 7, false, 0},
+		{"198.51.100.1", models.IPRuleScopeUser, 7, true, 0},
+		{"198.51.100.1", models.IPRuleScopeUser, 8, false, 0}, // 其他用户的规则不生效
+		{"not-an-ip", models.IPRuleScopeAuth, 0, true, 0},
+	}
+	for _, tt := range tests {
+		err := ipfilter.Evaluate(rules, tt.ip, tt.scope, tt.userID)
+		var denied *ipfilter.Denied
+		if !tt.denied {
+			if err != nil {
+				t.Errorf("%s in %s (user %d): unexpected %v", tt.ip, tt.scope, tt.userID, err)
+			}
+			continue
+		}
+		if !errors.As(err, &denied) {
+			t.Errorf("%s in %s (user %d): got %v, want *Denied", tt.ip, tt.scope, tt.userID, err)
+			continue
+		}
+		if got := denied.Rule; (got == nil) != (tt.ruleID == 0) || (got != nil && got.ID != tt.ruleID) {
+			t.Errorf("%s in %s (user %d): denied by %+v, want rule %d", tt.ip, tt.scope, tt.userID, got, tt.ruleID)
+		}
+	}
+}
+
+func TestCheckUsesCachedRules(t *testing.T) {
+	t.Setenv("DATA_PATH", t.TempDir())
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	filter := ipfilter.New(db)
+
+	if err := filter.Check("203.0.113.9", models.IPRuleScopePublic, 0); err != nil {
+		t.Fatalf("no rules: %v", err)
+	}
+	rule := models.IPRule{Action: models.IPRuleDeny, CIDR: "203.0.113.0/24", Scope: models.IPRuleScopePublic}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	if err := filter.Check("203.0.113.9", models.IPRuleScopePublic, 0); err != nil {
+		t.Fatalf("rules should be cached until Invalidate: %v", err)
+	}
+	filter.Invalidate()
+	if err := filter.Check("203.0.113.9", models.IPRuleScopePublic, 0); err == nil {
+		t.Fatal("new rule should apply after Invalidate")
+	}
+}
@@ -0,0 +1,445 @@
+// Package keyring 管理 JWT 签名密钥，支持 HS256、RS256、EdDSA、密钥轮换和 JWKS 发布
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// reloadInterval 遇到未知 kid 时最多每隔多久从数据库重新加载一次，用于感知其他实例的轮换
+const reloadInterval = 10 * time.Second
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrKeyExpired           = errors.New("signing key expired")
+)
+
+var signingMethods = map[string]jwt.SigningMethod{
+	AlgHS256: jwt.SigningMethodHS256,
+	AlgRS256: jwt.SigningMethodRS256,
+	AlgEdDSA: jwt.SigningMethodEdDSA,
+}
+
+// Key 已加载的签名密钥
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	RetiredAt *time.Time
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring 签名密钥环：最新的未退役密钥用于签名，退役密钥在宽限期内仍可验证
+type Keyring struct {
+	db        *gorm.DB
+	algorithm string
+	issuer    string
+	audience  string
+	grace     time.Duration
+
+	mu       sync.RWMutex
+	active   *Key
+	keys     map[string]*Key
+	loadedAt time.Time
+}
+
+// New 从数据库加载密钥；没有可用密钥或配置的算法变更时生成新密钥。
+// HS256 配置了 JWT_SECRET 时只在密钥环为空时使用它，之后以数据库中的密钥为准，避免重启后撤销管理员的轮换
+func New(db *gorm.DB, cfg *config.Config) (*Keyring, error) {
+	if _, ok := signingMethods[cfg.JWTAlgorithm]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, cfg.JWTAlgorithm)
+	}
+
+	k := &Keyring{
+		db:        db,
+		algorithm: cfg.JWTAlgorithm,
+		issuer:    cfg.JWTIssuer,
+		audience:  cfg.JWTAudience,
+		grace:     cfg.JWTKeyGrace,
+	}
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+
+	if k.algorithm == AlgHS256 && cfg.JWTSecret != "" {
+		kid := secretKeyID(cfg.JWTSecret)
+		k.mu.RLock()
+		empty := len(k.keys) == 0
+		k.mu.RUnlock()
+		if empty {
+			record := models.SigningKey{
+				KID:       kid,
+				Algorithm: AlgHS256,
+				Secret:    base64.StdEncoding.EncodeToString([]byte(cfg.JWTSecret)),
+			}
+			if err := k.activate(&record); err != nil {
+				return nil, err
+			}
+			return k, nil
+		}
+		if active := k.Active(); active != nil && active.ID != kid {
+			log.Printf("JWT_SECRET does not match the active signing key %s, keeping the key in the database", active.ID)
+		}
+	}
+
+	if active := k.Active(); active == nil || active.Algorithm != k.algorithm {
+		if _, err := k.Rotate(k.algorithm); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Issuer 签发令牌时写入的 iss
+func (k *Keyring) Issuer() string {
+	return k.issuer
+}
+
+// Audience 访问令牌的 aud
+func (k *Keyring) Audience() string {
+	return k.audience
+}
+
+// Active 返回当前用于签名的密钥
+func (k *Keyring) Active() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Sign 使用当前密钥签名，并在头部写入 kid、在声明中写入 iss
+func (k *Keyring) Sign(claims jwt.MapClaims) (string, error) {
+	key := k.Active()
+	if key == nil {
+		return "", ErrUnknownKey
+	}
+
+	if _, ok := claims["iss"]; !ok && k.issuer != "" {
+		claims["iss"] = k.issuer
+	}
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = time.Now().Unix()
+	}
+
+	token := jwt.NewWithClaims(signingMethods[key.Algorithm], claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
+}
+
+// Parse 校验令牌签名、算法、签发者和有效期，opts 可追加受众等校验
+func (k *Keyring) Parse(tokenString string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+	}
+	if k.issuer != "" {
+		options = append(options, jwt.WithIssuer(k.issuer))
+	}
+	options = append(options, opts...)
+
+	token, err := jwt.Parse(tokenString, k.keyfunc, options...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
+
+// keyfunc 按 kid 查找验证密钥，令牌声明的算法必须与密钥的算法一致
+func (k *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := k.lookup(kid)
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, token.Method.Alg())
+	}
+	if k.expired(key, time.Now()) {
+		return nil, ErrKeyExpired
+	}
+	return key.verifyKey, nil
+}
+
+func (k *Keyring) lookup(kid string) *Key {
+	if kid == "" {
+		return nil
+	}
+
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	stale := time.Since(k.loadedAt) > reloadInterval
+	k.mu.RUnlock()
+	if ok || !stale {
+		return key
+	}
+
+	// 可能是其他实例轮换出的新密钥
+	if err := k.reload(); err != nil {
+		return nil
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+// expired 退役密钥超过宽限期后不再接受
+func (k *Keyring) expired(key *Key, now time.Time) bool {
+	return key.RetiredAt != nil && now.After(key.RetiredAt.Add(k.grace))
+}
+
+// Rotate 生成新的签名密钥并退役当前密钥，同时清理宽限期已过的旧密钥
+func (k *Keyring) Rotate(algorithm string) (*Key, error) {
+	if algorithm == "" {
+		algorithm = k.algorithm
+	}
+	secret, err := generateSecret(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randomKeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.SigningKey{KID: kid, Algorithm: algorithm, Secret: secret}
+	if err := k.activate(&record); err != nil {
+		return nil, err
+	}
+	return k.Active(), nil
+}
+
+// activate 保存新密钥并退役其他密钥
+func (k *Keyring) activate(record *models.SigningKey) error {
+	now := time.Now()
+	err := k.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("retired_at IS NOT NULL AND retired_at < ?", now.Add(-k.grace)).
+			Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SigningKey{}).Where("retired_at IS NULL").
+			Update("retired_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return err
+	}
+	return k.reload()
+}
+
+// reload 从数据库重新加载全部密钥
+func (k *Keyring) reload() error {
+	var records []models.SigningKey
+	if err := k.db.Order("id asc").Find(&records).Error; err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key, len(records))
+	var active *Key
+	for _, record := range records {
+		key, err := parseKey(record)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", record.KID, err)
+		}
+		keys[key.ID] = key
+		if key.RetiredAt == nil {
+			active = key
+		}
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.active = active
+	k.loadedAt = time.Now()
+	return nil
+}
+
+// Keys 返回仍在有效期内的全部密钥，按创建时间倒序
+func (k *Keyring) Keys() []*Key {
+	now := time.Now()
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		if !k.expired(key, now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys
+}
+
+// ExpiresAt 返回退役密钥宽限期的结束时间，未退役时为 nil
+func (k *Keyring) ExpiresAt(key *Key) *time.Time {
+	if key.RetiredAt == nil {
+		return nil
+	}
+	t := key.RetiredAt.Add(k.grace)
+	return &t
+}
+
+// JWK JSON Web Key（RFC 7517）中的公钥
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet JWKS 文档
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 发布全部有效的非对称公钥，HS256 密钥不会公开
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.Keys() {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// parseKey 将数据库记录解析为签名和验证密钥
+func parseKey(record models.SigningKey) (*Key, error) {
+	key := &Key{
+		ID:        record.KID,
+		Algorithm: record.Algorithm,
+		CreatedAt: record.CreatedAt,
+		RetiredAt: record.RetiredAt,
+	}
+
+	if record.Algorithm == AlgHS256 {
+		secret, err := base64.StdEncoding.DecodeString(record.Secret)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, key.verifyKey = secret, secret
+		return key, nil
+	}
+
+	block, _ := pem.Decode([]byte(record.Secret))
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch priv := private.(type) {
+	case *rsa.PrivateKey:
+		if record.Algorithm != AlgRS256 {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		key.signKey, key.verifyKey = priv, &priv.PublicKey
+	case ed25519.PrivateKey:
+		if record.Algorithm != AlgEdDSA {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		key.signKey, key.verifyKey = priv, priv.Public().(ed25519.PublicKey)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	return key, nil
+}
+
+// generateSecret 按算法生成新的密钥材料
+func generateSecret(algorithm string) (string, error) {
+	var private interface{}
+	switch algorithm {
+	case AlgHS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(secret), nil
+	case AlgRS256:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", err
+		}
+		private = priv
+	case AlgEdDSA:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private = priv
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+func randomKeyID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// secretKeyID 由配置的 HS256 密钥派生 kid，不泄露密钥本身
+func secretKeyID(secret string) string {
+	sum := sha256.Sum256([]byte("go-admin-jwt-secret:" + secret))
+	return "hs-" + hex.EncodeToString(sum[:8])
+}
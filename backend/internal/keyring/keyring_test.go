@@ -0,0 +1,115 @@
+package keyring_test
+
+import (
+	"testing"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/keyring"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) (*gorm.DB, *config.Config) {
+	t.Helper()
+	t.Setenv("DATA_PATH", t.TempDir())
+	cfg := config.LoadConfig()
+	db, err := database.InitDB(cfg)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db, cfg
+}
+
+func TestSignAndParse(t *testing.T) {
+	db, cfg := newTestDB(t)
+	for _, algorithm := range []string{keyring.AlgRS256, keyring.AlgEdDSA, keyring.AlgHS256} {
+		cfg.JWTAlgorithm = algorithm
+		keys, err := keyring.New(db, cfg)
+		if err != nil {
+			t.Fatalf("New(%s): %v", algorithm, err)
+		}
+		if active := keys.Active(); active == nil || active.Algorithm != algorithm {
+			t.Fatalf("%s: unexpected active key %+v", algorithm, active)
+		}
+
+		token, err := keys.Sign(jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatalf("%s: Sign: %v", algorithm, err)
+		}
+		claims, err := keys.Parse(token)
+		if err != nil || claims["sub"] != "1" || claims["iss"] != cfg.JWTIssuer {
+			t.Fatalf("%s: Parse: %v, %v", algorithm, claims, err)
+		}
+	}
+}
+
+func TestRotateKeepsOldKeyDuringGrace(t *testing.T) {
+	db, cfg := newTestDB(t)
+	keys, err := keyring.New(db, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	old, err := keys.Sign(jwt.MapClaims{"exp": time.Now().Add(time.Minute).Unix()})
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	if _, err := keys.Rotate(""); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if _, err := keys.Parse(old); err != nil {
+		t.Fatalf("token signed by the retired key should verify during the grace period: %v", err)
+	}
+	if n := len(keys.JWKS().Keys); n != 2 {
+		t.Fatalf("JWKS should publish both keys, got %d", n)
+	}
+}
+
+func TestSecretOnlySeedsEmptyKeyring(t *testing.T) {
+	db, cfg := newTestDB(t)
+	cfg.JWTAlgorithm = keyring.AlgHS256
+	cfg.JWTSecret = "configured-secret"
+	cfg.JWTKeyGrace = 0
+
+	keys, err := keyring.New(db, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	seeded := keys.Active().ID
+
+	// 管理员轮换后宽限期已过，再次轮换时清理掉由 JWT_SECRET 创建的密钥
+	rotated, err := keys.Rotate("")
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if rotated, err = keys.Rotate(""); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	// 重启后不能重新启用 JWT_SECRET，撤销管理员的轮换
+	restarted, err := keyring.New(db, cfg)
+	if err != nil {
+		t.Fatalf("New after restart: %v", err)
+	}
+	if active := restarted.Active(); active.ID != rotated.ID || active.ID == seeded {
+		t.Fatalf("restart should keep the rotated key %s, got %s", rotated.ID, active.ID)
+	}
+
+	// 修改 JWT_SECRET 也不会替换数据库中的密钥
+	cfg.JWTSecret = "another-secret"
+	if restarted, err = keyring.New(db, cfg); err != nil {
+		t.Fatalf("New with another secret: %v", err)
+	}
+	if active := restarted.Active(); active.ID != rotated.ID {
+		t.Fatalf("changed secret should not replace the active key, got %s", active.ID)
+	}
+}
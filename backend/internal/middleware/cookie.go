@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cookie 模式下使用的 Cookie 和请求头
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	CSRFCookie         = "csrf_token" // 前端可读取，修改类请求通过 CSRFHeader 回传
+	CSRFHeader         = "X-CSRF-Token"
+	OIDCBindingCookie  = "oidc_binding" // 把单点登录请求绑定到发起它的浏览器
+
+	// refreshTokenCookiePath 刷新令牌只随刷新请求发送
+	refreshTokenCookiePath = "/api/token/refresh"
+)
+
+func sameSite(cfg *config.Config) http.SameSite {
+	switch cfg.CookieSameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+func setCookie(c *gin.Context, cfg *config.Config, name, value, path string, ttl time.Duration, httpOnly bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.CookieDomain,
+		Secure:   cfg.CookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite(cfg),
+	}
+	if ttl > 0 {
+		cookie.MaxAge = int(ttl.Seconds())
+		cookie.Expires = time.Now().Add(ttl)
+	} else {
+		cookie.MaxAge = -1
+		cookie.Expires = time.Unix(0, 0)
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+// SetAuthCookies 将令牌写入 HttpOnly Cookie，并签发新的 CSRF 令牌；sessionTTL 为刷新令牌的有效期
+func SetAuthCookies(c *gin.Context, cfg *config.Config, accessToken, refreshToken string, sessionTTL time.Duration) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	setCookie(c, cfg, AccessTokenCookie, accessToken, "/", cfg.AccessTokenTTL, true)
+	setCookie(c, cfg, RefreshTokenCookie, refreshToken, refreshTokenCookiePath, sessionTTL, true)
+	setCookie(c, cfg, CSRFCookie, base64.RawURLEncoding.EncodeToString(b), "/", sessionTTL, false)
+	return nil
+}
+
+// ClearAuthCookies 登出时清除令牌和 CSRF Cookie
+func ClearAuthCookies(c *gin.Context, cfg *config.Config) {
+	setCookie(c, cfg, AccessTokenCookie, "", "/", 0, true)
+	setCookie(c, cfg, RefreshTokenCookie, "", refreshTokenCookiePath, 0, true)
+	setCookie(c, cfg, CSRFCookie, "", "/", 0, false)
+}
+
+// SetOIDCBindingCookie 写入单点登录请求的绑定值。固定使用 SameSite=Lax：
+// 身份提供方跳转回回调地址是跨站的顶级导航，Strict 不会携带该 Cookie
+func SetOIDCBindingCookie(c *gin.Context, cfg *config.Config, value string, ttl time.Duration) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     OIDCBindingCookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   int(ttl.Seconds()),
+		Expires:  time.Now().Add(ttl),
+		Secure:   cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearOIDCBindingCookie 回调处理后清除绑定值
+func ClearOIDCBindingCookie(c *gin.Context, cfg *config.Config) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     OIDCBindingCookie,
+		Path:     "/",
+		Domain:   cfg.CookieDomain,
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		Secure:   cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ValidCSRF 双重提交校验：修改类请求的 CSRFHeader 必须与 CSRFCookie 一致
+func ValidCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := c.Cookie(CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	if err != nil || cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
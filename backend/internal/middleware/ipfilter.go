@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"backend/internal/ipfilter"
+	"backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilter 按全局和路由组 group 的访问规则限制来源 IP，应放在认证中间件之前
+func IPFilter(filter *ipfilter.Filter, group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejectIP(c, filter, models.IPRuleScopeGlobal, 0) || rejectIP(c, filter, group, 0) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserIPFilter 按当前用户的访问规则限制来源 IP，应放在认证中间件之后
+func UserIPFilter(filter *ipfilter.Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user, ok := c.Get("user"); ok && RejectUserIP(c, filter, user.(*models.User)) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RejectUserIP 检查用户级访问规则，拒绝时已写入响应并返回 true，登录接口同样使用
+func RejectUserIP(c *gin.Context, filter *ipfilter.Filter, user *models.User) bool {
+	return rejectIP(c, filter, models.IPRuleScopeUser, user.ID)
+}
+
+// rejectIP 来源 IP 未通过规则时记录日志并中止请求
+func rejectIP(c *gin.Context, filter *ipfilter.Filter, scope string, userID uint) bool {
+	err := filter.Check(c.ClientIP(), scope, userID)
+	if err == nil {
+		return false
+	}
+
+	var denied *ipfilter.Denied
+	if errors.As(err, &denied) {
+		log.Printf("Rejected request from %s to %s %s (user %d): %v", c.ClientIP(), c.Request.Method, c.Request.URL.Path, userID, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "当前 IP 不允许访问"})
+	} else {
+		log.Printf("Failed to check IP rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查访问规则失败"})
+	}
+	c.Abort()
+	return true
+}
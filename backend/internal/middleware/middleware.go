@@ -1,19 +1,42 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
 	"net/http"
 	"strings"
+	"time"
+	"backend/internal/config"
+	"backend/internal/keyring"
 	"backend/internal/models"
+	"backend/internal/optioncache"
+	"backend/internal/usercache"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
-func CORS() gin.HandlerFunc {
+// CORS Cookie 模式下浏览器会携带凭据，不能使用通配符来源，只回显允许的来源
+func CORS(cfg *config.Config) gin.HandlerFunc {
+	allowedOrigins := make(map[string]bool, len(cfg.CORSAllowedOrigins))
+	for _, origin := range cfg.CORSAllowedOrigins {
+		allowedOrigins[strings.TrimRight(origin, "/")] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if cfg.AuthMode == config.AuthModeCookie {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowedOrigins[origin] {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+CSRFHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -24,45 +47,267 @@ func CORS() gin.HandlerFunc {
 	}
 }
 
-func Auth(jwtSecret string) gin.HandlerFunc {
+// passwordChangeRoutes 必须修改密码时仍允许访问的接口
+var passwordChangeRoutes = map[string]bool{
+	"PUT /api/user/password": true,
+	"POST /api/logout":       true,
+}
+
+// Auth 校验访问令牌的签名、算法、签发者和受众，以及令牌和会话是否已失效；
+// 用户信息以数据库为准，令牌中的权限声明不再生效
+func Auth(db *gorm.DB, cfg *config.Config, keys *keyring.Keyring, users *usercache.Cache, options *optioncache.Cache) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
+		tokenString := ""
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			tokenString = strings.Replace(authHeader, "Bearer ", "", 1)
+		} else if cfg.AuthMode == config.AuthModeCookie {
+			// 浏览器会自动携带 Cookie，修改类请求必须同时提交 CSRF 令牌
+			tokenString, _ = c.Cookie(AccessTokenCookie)
+			if tokenString != "" && !ValidCSRF(c) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "CSRF 校验失败"})
+				c.Abort()
+				return
+			}
+		}
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供认证信息"})
 			c.Abort()
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtSecret), nil
-		})
+		if strings.HasPrefix(tokenString, models.APITokenPrefix) {
+			authenticateAPIToken(c, db, users, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		claims, err := keys.Parse(tokenString, jwt.WithAudience(keys.Audience()))
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证信息"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		// 检查令牌是否已被吊销
+		jti, _ := claims["jti"].(string)
+		if jti == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证信息"})
 			c.Abort()
 			return
 		}
 
-		user := &models.User{
-			ID:       uint(claims["id"].(float64)),
-			Username: claims["username"].(string),
-			IsAdmin:  claims["is_admin"].(bool),
+		var count int64
+		if err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "验证令牌失败"})
+			c.Abort()
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+			c.Abort()
+			return
+		}
+
+		// 检查会话是否仍然有效
+		sessionID, _ := claims["sid"].(string)
+		var session models.Session
+		if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil || session.RevokedAt != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+			c.Abort()
+			return
+		}
+
+		// 长时间无操作的会话失效，需要重新登录
+		now := time.Now()
+		idleTimeout := time.Duration(options.Int(models.OptionSessionIdleTimeoutMinutes, 0)) * time.Minute
+		if session.IsIdle(idleTimeout, now) {
+			db.Model(&session).UpdateColumn("revoked_at", now)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "会话因长时间未操作已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
+		// 降低写入频率，最近活跃时间精确到分钟即可；空闲时间很短时相应提高精度
+		writeInterval := time.Minute
+		if idleTimeout > 0 && idleTimeout/2 < writeInterval {
+			writeInterval = idleTimeout / 2
+		}
+		if now.Sub(session.LastSeenAt) > writeInterval {
+			db.Model(&session).UpdateColumn("last_seen_at", now)
+		}
+
+		// 用户被删除或停用后令牌立即失效
+		user, err := users.Get(session.UserID)
+		if err != nil || user.IsDisabled(now) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+			c.Abort()
+			return
+		}
+
+		// 模拟登录：发起模拟的管理员被删除或失去模拟权限后立即结束，每个请求都记录实际操作的管理员
+		if session.ImpersonatorID != nil {
+			impersonator, err := users.Get(*session.ImpersonatorID)
+			if err != nil || !impersonator.HasPermission(models.PermissionUsersImpersonate) || impersonator.IsDisabled(now) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+				c.Abort()
+				return
+			}
+			defer auditImpersonation(db, c, &session)
+			c.Set("impersonator", impersonator)
+
+			if impersonationBlocked(c.Request.Method, c.FullPath()) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "模拟登录期间不能执行该操作"})
+				c.Abort()
+				return
+			}
+		}
+
+		if mustChangePassword(c, user) {
+			return
 		}
 
 		c.Set("user", user)
+		c.Set("claims", claims)
+		c.Set("session", &session)
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// mustChangePassword 需要修改密码的用户只能访问修改密码相关的接口，拦截时返回 true
+func mustChangePassword(c *gin.Context, user *models.User) bool {
+	if user.MustChangePassword && !passwordChangeRoutes[c.Request.Method+" "+c.FullPath()] {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":                "请先修改密码",
+			"must_change_password": true,
+		})
+		c.Abort()
+		return true
+	}
+	return false
+}
+
+// sessionOnlyRoutes 涉及账户安全、只能在登录会话中访问的接口前缀，API 令牌不能访问
+var sessionOnlyRoutes = []string{
+	"/api/logout",
+	"/api/user/password",
+	"/api/user/sessions",
+	"/api/user/2fa",
+	"/api/user/passkeys",
+	"/api/user/oidc",
+	"/api/admin/users/:id/impersonate",
+}
+
+// impersonationReadOnlyRoutes 模拟登录期间只能查看、不能修改的接口前缀：账户凭据、安全设置和再次模拟
+var impersonationReadOnlyRoutes = []string{
+	"/api/user/password",
+	"/api/user/sessions",
+	"/api/user/2fa",
+	"/api/user/passkeys",
+	"/api/user/tokens",
+	"/api/user/oidc",
+	"/api/admin/users/:id/impersonate",
+}
+
+func impersonationBlocked(method, path string) bool {
+	if method == http.MethodGet || method == http.MethodHead {
+		return false
+	}
+	for _, prefix := range impersonationReadOnlyRoutes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditImpersonation 在请求结束后记录模拟登录期间的请求
+func auditImpersonation(db *gorm.DB, c *gin.Context, session *models.Session) {
+	audit := models.ImpersonationAudit{
+		SessionID:      session.SessionID,
+		ImpersonatorID: *session.ImpersonatorID,
+		UserID:         session.UserID,
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		Status:         c.Writer.Status(),
+		IP:             c.ClientIP(),
+	}
+	if err := db.Create(&audit).Error; err != nil {
+		log.Printf("Failed to record impersonation audit: %v", err)
+	}
+}
+
+// apiTokenAllows 按权限范围判断 API 令牌能否访问当前接口；所有者的权限仍由 RequirePermission 校验
+func apiTokenAllows(token *models.APIToken, method, path string) bool {
+	for _, prefix := range sessionOnlyRoutes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	// 不能用令牌创建新令牌，避免扩大权限或延长有效期
+	if method == http.MethodPost && path == "/api/user/tokens" {
+		return false
+	}
+
+	if token.HasScope(models.ScopeAdmin) {
+		return true
+	}
+	readOnly := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case strings.HasPrefix(path, "/api/admin/users"):
+		return token.HasScope(models.ScopeUserAdmin) || (readOnly && token.HasScope(models.ScopeRead))
+	case strings.HasPrefix(path, "/api/admin/"):
+		return readOnly && token.HasScope(models.ScopeRead)
+	default:
+		return token.HasScope(models.ScopeWrite) || (readOnly && token.HasScope(models.ScopeRead))
+	}
+}
+
+// authenticateAPIToken 校验 API 令牌，记录最近使用时间和 IP
+func authenticateAPIToken(c *gin.Context, db *gorm.DB, users *usercache.Cache, tokenString string) {
+	sum := sha256.Sum256([]byte(tokenString))
+	var token models.APIToken
+	if err := db.Where("token_hash = ?", hex.EncodeToString(sum[:])).First(&token).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证信息"})
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && token.ExpiresAt.Before(now)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+		c.Abort()
+		return
+	}
+
+	user, err := users.Get(token.UserID)
+	if err != nil || user.IsDisabled(now) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "认证信息已失效"})
+		c.Abort()
+		return
+	}
+
+	if !apiTokenAllows(&token, c.Request.Method, c.FullPath()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API 令牌的权限范围不允许访问该接口"})
+		c.Abort()
+		return
+	}
+
+	// 同一 IP 的使用记录精确到分钟即可
+	ip := c.ClientIP()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > time.Minute || token.LastUsedIP != ip {
+		db.Model(&token).UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	if mustChangePassword(c, user) {
+		return
+	}
+
+	c.Set("user", user)
+	c.Set("api_token", &token)
+	c.Next()
+}
+
+// AdminOnly 只允许拥有任一管理权限或命中 allow 策略的用户访问管理接口，具体接口所需的权限由 RequirePermission 校验
+func AdminOnly(options *optioncache.Cache) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
@@ -71,12 +316,39 @@ func AdminOnly() gin.HandlerFunc {
 			return
 		}
 
-		if !user.(*models.User).IsAdmin {
+		if !user.(*models.User).IsAdmin() && !policyAllowed(c) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
 			c.Abort()
 			return
 		}
 
+		// 开启强制两步验证后，未启用两步验证的管理员不能使用管理功能
+		if options.Value(models.OptionRequireAdmin2FA) == "true" && !user.(*models.User).TOTPEnabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "管理员需要先启用两步验证"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
+
+// RequirePermission 要求当前用户的角色包含指定权限，命中 allow 策略时不再检查，需在 Auth 之后使用
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+			c.Abort()
+			return
+		}
+
+		if !user.(*models.User).HasPermission(permission) && !policyAllowed(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限执行该操作", "permission": permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
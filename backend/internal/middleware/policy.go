@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"backend/internal/models"
+	"backend/internal/policy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// policyAllowedKey 命中 allow 策略时写入上下文，AdminOnly 和 RequirePermission 据此放行
+const policyAllowedKey = "policy_allowed"
+
+// Policy 按接口策略检查当前用户能否访问匹配到的路由，应放在认证中间件之后、AdminOnly 之前
+func Policy(engine *policy.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("user")
+		if !ok {
+			c.Next()
+			return
+		}
+		user := value.(*models.User)
+
+		result, err := engine.Check(user, c.Request.Method, c.FullPath())
+		if err != nil {
+			log.Printf("Failed to check policies: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "检查访问策略失败"})
+			c.Abort()
+			return
+		}
+
+		switch result.Decision {
+		case policy.DecisionDeny:
+			log.Printf("Rejected %s %s for user %d by policy %d", c.Request.Method, c.FullPath(), user.ID, result.Policy.ID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "访问策略禁止该操作", "policy_id": result.Policy.ID})
+			c.Abort()
+			return
+		case policy.DecisionAllow:
+			// 模拟登录期间不授予额外权限，避免借被模拟用户的策略访问管理接口
+			if _, impersonated := c.Get("impersonator"); !impersonated {
+				c.Set(policyAllowedKey, true)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// policyAllowed 判断当前请求是否命中了 allow 策略
+func policyAllowed(c *gin.Context) bool {
+	return c.GetBool(policyAllowedKey)
+}
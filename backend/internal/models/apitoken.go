@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APITokenPrefix API 令牌的前缀，用于与 JWT 区分
+const APITokenPrefix = "gat_"
+
+// API 令牌的权限范围
+const (
+	ScopeRead      = "read"       // 只读访问所有者可访问的接口
+	ScopeWrite     = "write"      // 修改所有者自己的数据，不包括管理接口
+	ScopeUserAdmin = "user-admin" // 用户管理接口，所有者须为管理员
+	ScopeAdmin     = "admin"      // 所有管理接口，所有者须为管理员
+)
+
+// APITokenScopes 所有可用的权限范围
+var APITokenScopes = []string{ScopeRead, ScopeWrite, ScopeUserAdmin, ScopeAdmin}
+
+// APIToken 用户为脚本和 CI 创建的长期令牌，仅保存哈希值
+type APIToken struct {
+	ID         uint   `gorm:"primarykey"`
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"not null"` // 令牌开头的几个字符，便于用户辨认
+	TokenHash  string `gorm:"uniqueIndex;not null"`
+	Scopes     string `gorm:"not null"` // 空格分隔
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// ScopeList 返回权限范围列表
+func (t *APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// HasScope 判断令牌是否拥有指定权限范围
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type APITokenResponse struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	Username   string     `json:"username,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=64"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空时永不过期
+}
+
+// RotateServiceAccountCredentialsRequest 轮换服务账户凭据；旧凭据在重叠期内仍然有效
+type RotateServiceAccountCredentialsRequest struct {
+	Name      string     `json:"name"`       // 为空时沿用最近一个凭据的名称
+	Scopes    []string   `json:"scopes"`     // 为空时沿用最近一个凭据的权限范围
+	ExpiresAt *time.Time `json:"expires_at"` // 新凭据的过期时间，为空时永不过期
+	Overlap   string     `json:"overlap"`    // 旧凭据的剩余有效期，如 24h，为空时使用默认值
+}
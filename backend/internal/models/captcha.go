@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Captcha 服务端保存的验证码，只保存答案的哈希值，校验一次后即删除
+type Captcha struct {
+	ID         uint      `gorm:"primarykey"`
+	CaptchaID  string    `gorm:"uniqueIndex;not null"`
+	AnswerHash string    `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	CreatedAt  time.Time
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// ImpersonationAudit 模拟登录期间的每个请求，记录实际操作的管理员
+type ImpersonationAudit struct {
+	ID             uint   `gorm:"primarykey"`
+	SessionID      string `gorm:"index;not null"`
+	ImpersonatorID uint   `gorm:"index;not null"`
+	UserID         uint   `gorm:"index;not null"`
+	Method         string `gorm:"not null"`
+	Path           string `gorm:"not null"`
+	Status         int
+	IP             string
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+type ImpersonationAuditResponse struct {
+	ID                   uint      `json:"id"`
+	SessionID            string    `json:"session_id"`
+	ImpersonatorID       uint      `json:"impersonator_id"`
+	ImpersonatorUsername string    `json:"impersonator_username"`
+	UserID               uint      `json:"user_id"`
+	Username             string    `json:"username"`
+	Method               string    `json:"method"`
+	Path                 string    `json:"path"`
+	Status               int       `json:"status"`
+	IP                   string    `json:"ip"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// ImpersonatorInfo 当前请求处于模拟登录时的实际操作者
+type ImpersonatorInfo struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"` // 记录在会话和审计日志中
+}
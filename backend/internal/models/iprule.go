@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// IP 访问规则的动作
+const (
+	IPRuleAllow = "allow"
+	IPRuleDeny  = "deny"
+)
+
+// IP 访问规则的作用范围：全局、路由组或单个用户
+const (
+	IPRuleScopeGlobal = "global" // 所有 /api 接口
+	IPRuleScopePublic = "public" // 登录、注册等公开接口
+	IPRuleScopeAuth   = "auth"   // 需要登录的接口
+	IPRuleScopeAdmin  = "admin"  // 管理接口
+	IPRuleScopeUser   = "user"   // 指定用户的登录和访问
+)
+
+// IPRuleScopes 所有可用的作用范围
+var IPRuleScopes = []string{IPRuleScopeGlobal, IPRuleScopePublic, IPRuleScopeAuth, IPRuleScopeAdmin, IPRuleScopeUser}
+
+// IPRule 基于 CIDR 的访问规则；同一作用范围内命中 deny 规则即拒绝，存在 allow 规则时只允许命中的来源
+type IPRule struct {
+	ID          uint   `gorm:"primarykey"`
+	Action      string `gorm:"not null"`
+	CIDR        string `gorm:"column:cidr;not null"` // 单个 IP 保存为 /32 或 /128
+	Scope       string `gorm:"index;not null"`
+	UserID      *uint  `gorm:"index"` // 作用范围为 user 时有效
+	Description string
+	CreatedAt   time.Time
+}
+
+type IPRuleResponse struct {
+	ID          uint      `json:"id"`
+	Action      string    `json:"action"`
+	CIDR        string    `json:"cidr"`
+	Scope       string    `json:"scope"`
+	UserID      *uint     `json:"user_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateIPRuleRequest struct {
+	Action      string `json:"action" binding:"required"`
+	CIDR        string `json:"cidr" binding:"required"` // CIDR 或单个 IP
+	Scope       string `json:"scope" binding:"required"`
+	UserID      *uint  `json:"user_id"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// 登录限制的对象类型
+const (
+	ThrottleKindUsername = "username"
+	ThrottleKindIP       = "ip"
+)
+
+// LoginThrottle 按用户名或IP统计的连续登录失败，用于退避和临时锁定
+type LoginThrottle struct {
+	ID            uint   `gorm:"primarykey"`
+	Kind          string `gorm:"uniqueIndex:idx_login_throttle_subject;not null"`
+	Subject       string `gorm:"uniqueIndex:idx_login_throttle_subject;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time `gorm:"index"`
+}
+
+type LoginThrottleResponse struct {
+	ID            uint       `json:"id"`
+	Kind          string     `json:"kind"`
+	Subject       string     `json:"subject"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	Locked        bool       `json:"locked"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// OIDCState 进行中的单点登录请求，回调时校验并删除
+type OIDCState struct {
+	ID           uint      `gorm:"primarykey"`
+	State        string    `gorm:"uniqueIndex;not null"`
+	Nonce        string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"` // PKCE 验证码
+	BindingHash  string    // 发起请求的浏览器中绑定 Cookie 的哈希，防止把回调地址发给他人完成登录
+	LinkUserID   *uint     // 已登录用户主动关联外部身份时为该用户，否则为登录请求
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	CreatedAt    time.Time
+}
+
+// OIDCLoginCode 单点登录回调签发的一次性交换码，前端用它换取令牌，避免令牌出现在跳转 URL 中
+type OIDCLoginCode struct {
+	ID        uint      `gorm:"primarykey"`
+	CodeHash  string    `gorm:"uniqueIndex;not null"`
+	UserID    uint      `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+type OIDCExchangeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// UserIdentity 用户关联的外部身份
+type UserIdentity struct {
+	ID          uint   `gorm:"primarykey"`
+	UserID      uint   `gorm:"index;not null"`
+	Provider    string `gorm:"uniqueIndex:idx_user_identity_subject;not null"` // 身份提供方，OIDC 为其 issuer
+	Subject     string `gorm:"uniqueIndex:idx_user_identity_subject;not null"`
+	LastLoginAt *time.Time
+	CreatedAt   time.Time
+}
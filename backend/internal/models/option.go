@@ -1,7 +1,14 @@
 package models
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"backend/internal/captcha"
+
 	"gorm.io/gorm"
 )
 
@@ -33,6 +40,31 @@ const (
 	OptionAllowRegistration = "allow_registration"
 	// 系统名称
 	OptionSystemName = "system_name"
+	// 管理员必须启用两步验证
+	OptionRequireAdmin2FA = "require_admin_2fa"
+	// 登录失败锁定设置
+	OptionLoginMaxFailures    = "login_max_failures"
+	OptionLoginIPMaxFailures  = "login_ip_max_failures"
+	OptionLoginLockoutMinutes = "login_lockout_minutes"
+	OptionLoginBackoffSeconds = "login_backoff_seconds"
+	// 密码策略设置
+	OptionPasswordMinLength        = "password_min_length"
+	OptionPasswordRequireLowercase = "password_require_lowercase"
+	OptionPasswordRequireUppercase = "password_require_uppercase"
+	OptionPasswordRequireDigit     = "password_require_digit"
+	OptionPasswordRequireSymbol    = "password_require_symbol"
+	OptionPasswordForbidUsername   = "password_forbid_username"
+	OptionPasswordRejectCommon     = "password_reject_common"
+	OptionPasswordHistoryCount     = "password_history_count"
+	// 注册时需要验证邮箱
+	OptionRequireEmailVerification = "require_email_verification"
+	// 会话有效期设置
+	OptionSessionTTLHours           = "session_ttl_hours"
+	OptionRememberMeTTLHours        = "remember_me_ttl_hours"
+	OptionSessionIdleTimeoutMinutes = "session_idle_timeout_minutes"
+	// 验证码设置
+	OptionCaptchaType           = "captcha_type"
+	OptionLoginCaptchaThreshold = "login_captcha_threshold"
 	// 其他设置可以继续添加...
 )
 
@@ -59,4 +91,208 @@ var DefaultOptions = []Option{
 		Description:      "系统名称",
 		ReturnToFrontend: true,
 	},
+	{
+		OptionName:       OptionRequireAdmin2FA,
+		OptionValue:      "false",
+		AutoLoad:         true,
+		Description:      "管理员是否必须启用两步验证",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionLoginMaxFailures,
+		OptionValue:      "5",
+		AutoLoad:         true,
+		Description:      "同一用户名连续登录失败多少次后临时锁定",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionLoginIPMaxFailures,
+		OptionValue:      "20",
+		AutoLoad:         true,
+		Description:      "同一IP连续登录失败多少次后临时锁定",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionLoginLockoutMinutes,
+		OptionValue:      "15",
+		AutoLoad:         true,
+		Description:      "登录锁定时长（分钟）",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionLoginBackoffSeconds,
+		OptionValue:      "1",
+		AutoLoad:         true,
+		Description:      "登录失败退避基数（秒），每次失败后等待时间翻倍",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordMinLength,
+		OptionValue:      "8",
+		AutoLoad:         true,
+		Description:      "密码最小长度",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordRequireLowercase,
+		OptionValue:      "true",
+		AutoLoad:         true,
+		Description:      "密码是否必须包含小写字母",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordRequireUppercase,
+		OptionValue:      "false",
+		AutoLoad:         true,
+		Description:      "密码是否必须包含大写字母",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordRequireDigit,
+		OptionValue:      "true",
+		AutoLoad:         true,
+		Description:      "密码是否必须包含数字",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordRequireSymbol,
+		OptionValue:      "false",
+		AutoLoad:         true,
+		Description:      "密码是否必须包含特殊字符",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordForbidUsername,
+		OptionValue:      "true",
+		AutoLoad:         true,
+		Description:      "密码是否禁止包含用户名",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordRejectCommon,
+		OptionValue:      "true",
+		AutoLoad:         true,
+		Description:      "是否拒绝常见弱密码",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionPasswordHistoryCount,
+		OptionValue:      "5",
+		AutoLoad:         true,
+		Description:      "禁止重复使用最近几次的密码，0 表示不限制",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionRequireEmailVerification,
+		OptionValue:      "false",
+		AutoLoad:         true,
+		Description:      "注册时是否必须填写并验证邮箱，验证前不能登录",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionSessionTTLHours,
+		OptionValue:      "0",
+		AutoLoad:         true,
+		Description:      "登录会话有效期（小时），0 表示使用 REFRESH_TOKEN_TTL",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionRememberMeTTLHours,
+		OptionValue:      "720",
+		AutoLoad:         true,
+		Description:      "登录时勾选“记住我”的会话有效期（小时）",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionSessionIdleTimeoutMinutes,
+		OptionValue:      "0",
+		AutoLoad:         true,
+		Description:      "会话连续多少分钟无操作后失效，0 表示不限制",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionCaptchaType,
+		OptionValue:      "math",
+		AutoLoad:         true,
+		Description:      "验证码类型：math（算术题）或 text（随机字符）",
+		ReturnToFrontend: true,
+	},
+	{
+		OptionName:       OptionLoginCaptchaThreshold,
+		OptionValue:      "3",
+		AutoLoad:         true,
+		Description:      "同一用户名或IP连续登录失败多少次后登录需要验证码，0 表示不需要",
+		ReturnToFrontend: true,
+	},
+}
+
+// integerOptions 值必须为非负整数的配置项
+var integerOptions = map[string]bool{
+	OptionLoginMaxFailures:          true,
+	OptionLoginIPMaxFailures:        true,
+	OptionLoginLockoutMinutes:       true,
+	OptionLoginBackoffSeconds:       true,
+	OptionPasswordMinLength:         true,
+	OptionPasswordHistoryCount:      true,
+	OptionSessionTTLHours:           true,
+	OptionRememberMeTTLHours:        true,
+	OptionSessionIdleTimeoutMinutes: true,
+	OptionLoginCaptchaThreshold:     true,
+}
+
+// booleanOptions 值必须为 true 或 false 的配置项
+var booleanOptions = map[string]bool{
+	OptionAllowRegistration:        true,
+	OptionRequireAdmin2FA:          true,
+	OptionPasswordRequireLowercase: true,
+	OptionPasswordRequireUppercase: true,
+	OptionPasswordRequireDigit:     true,
+	OptionPasswordRequireSymbol:    true,
+	OptionPasswordForbidUsername:   true,
+	OptionPasswordRejectCommon:     true,
+	OptionRequireEmailVerification: true,
+}
+
+// enumOptions 值必须为给定取值之一的配置项
+var enumOptions = map[string][]string{
+	OptionCaptchaType: {captcha.TypeMath, captcha.TypeText},
+}
+
+// ValidateOptionValue 检查配置项的值格式，错误信息可直接返回给前端
+func ValidateOptionValue(name, value string) error {
+	if integerOptions[name] {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("配置项 %s 必须为非负整数", name)
+		}
+	}
+	if booleanOptions[name] && value != "true" && value != "false" {
+		return fmt.Errorf("配置项 %s 必须为 true 或 false", name)
+	}
+	if allowed, ok := enumOptions[name]; ok && !slices.Contains(allowed, value) {
+		return fmt.Errorf("配置项 %s 必须为 %s 之一", name, strings.Join(allowed, "、"))
+	}
+	return nil
+}
+
+// GetOptionValue 读取配置项的值，配置项不存在时返回默认值
+func GetOptionValue(db *gorm.DB, name string) string {
+	var option Option
+	if err := db.Where("option_name = ?", name).First(&option).Error; err == nil {
+		return option.OptionValue
+	}
+	for _, option := range DefaultOptions {
+		if option.OptionName == name {
+			return option.OptionValue
+		}
+	}
+	return ""
+}
+
+// GetOptionInt 读取整数配置项，无法解析时返回 fallback
+func GetOptionInt(db *gorm.DB, name string, fallback int) int {
+	value, err := strconv.Atoi(GetOptionValue(db, name))
+	if err != nil {
+		return fallback
+	}
+	return value
 } 
\ No newline at end of file
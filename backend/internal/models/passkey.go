@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// WebAuthnCredential 用户注册的通行密钥
+type WebAuthnCredential struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"index;not null"`
+	Name         string `gorm:"not null"`
+	CredentialID []byte `gorm:"uniqueIndex;not null"`
+	Data         string `gorm:"not null"` // 序列化后的凭据，包含公钥与签名计数
+	LastUsedAt   *time.Time
+	// CloneDetectedAt 签名计数回退、认证器疑似被克隆的时间，不为空时拒绝用它登录
+	CloneDetectedAt *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// WebAuthnChallenge 进行中的通行密钥注册或认证仪式
+type WebAuthnChallenge struct {
+	ID          uint      `gorm:"primarykey"`
+	ChallengeID string    `gorm:"uniqueIndex;not null"`
+	UserID      uint      `gorm:"index"` // 认证仪式开始时用户未知，为 0
+	Purpose     string    `gorm:"not null"`
+	Data        string    `gorm:"not null"` // 序列化后的仪式会话数据
+	ExpiresAt   time.Time `gorm:"index;not null"`
+	CreatedAt   time.Time
+}
+
+type PasskeyResponse struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CloneDetectedAt *time.Time `json:"clone_detected_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type RenamePasskeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
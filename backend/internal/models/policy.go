@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// 接口策略的效果
+const (
+	PolicyAllow = "allow"
+	PolicyDeny  = "deny"
+)
+
+// 接口策略的对象：所有登录用户、某个角色的用户或单个用户
+const (
+	PolicySubjectAll  = "all"
+	PolicySubjectRole = "role"
+	PolicySubjectUser = "user"
+)
+
+// PolicyMethodAny 匹配任意请求方法
+const PolicyMethodAny = "*"
+
+// Policy 按请求方法和路由控制需要登录的接口；命中 deny 策略即拒绝，
+// 命中 allow 策略时跳过角色权限检查，都未命中时按角色权限判断
+type Policy struct {
+	ID      uint   `gorm:"primarykey"`
+	Subject string `gorm:"index;not null"`
+	RoleID  *uint  `gorm:"index"` // 对象为 role 时有效
+	UserID  *uint  `gorm:"index"` // 对象为 user 时有效
+	Method  string `gorm:"not null"`
+	// gin 注册的路由，如 /api/admin/users/:id；以 /* 结尾时匹配该前缀下的所有路由，* 匹配全部路由
+	Path        string `gorm:"not null"`
+	Effect      string `gorm:"not null"`
+	Description string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type PolicyResponse struct {
+	ID          uint      `json:"id"`
+	Subject     string    `json:"subject"`
+	RoleID      *uint     `json:"role_id,omitempty"`
+	RoleName    string    `json:"role_name,omitempty"`
+	UserID      *uint     `json:"user_id,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Effect      string    `json:"effect"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type PolicyRequest struct {
+	Subject     string `json:"subject" binding:"required"`
+	RoleID      *uint  `json:"role_id"`
+	UserID      *uint  `json:"user_id"`
+	Method      string `json:"method" binding:"required"`
+	Path        string `json:"path" binding:"required"`
+	Effect      string `json:"effect" binding:"required"`
+	Description string `json:"description"`
+}
+
+type RouteResponse struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+}
+
+// PolicyExplanation 说明某个用户访问某个接口时策略的判断过程
+type PolicyExplanation struct {
+	UserID      uint             `json:"user_id"`
+	Username    string           `json:"username"`
+	Roles       []string         `json:"roles"`
+	Method      string           `json:"method"`
+	Path        string           `json:"path"`  // 请求的路径
+	Route       string           `json:"route"` // 匹配到的路由
+	Decision    string           `json:"decision"`
+	DecidedBy   *PolicyResponse  `json:"decided_by,omitempty"`
+	Matched     []PolicyResponse `json:"matched"`
+	Explanation string           `json:"explanation"`
+}
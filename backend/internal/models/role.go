@@ -0,0 +1,178 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 权限，路由通过 middleware.RequirePermission 声明所需的权限
+const (
+	PermissionAll              = "*" // 全部权限，只授予内置的 admin 角色
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write" // 创建、修改、删除、停用用户，管理会话和服务账户
+	PermissionUsersResetPass   = "users:reset_password"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionRolesRead        = "roles:read"
+	PermissionRolesWrite       = "roles:write" // 管理角色及用户的角色分配
+	PermissionTokensRead       = "tokens:read"
+	PermissionTokensWrite      = "tokens:write"
+	PermissionAuditRead        = "audit:read" // 安全事件、模拟登录记录和登录锁定
+	PermissionSecurityRead     = "security:read"
+	PermissionSecurityWrite    = "security:write" // IP 访问规则和签名密钥
+	PermissionSettingsRead     = "settings:read"
+	PermissionSettingsWrite    = "settings:write"
+	PermissionPoliciesRead     = "policies:read" // 接口策略可以绕过角色权限，修改策略需要全部权限
+)
+
+// PermissionInfo 权限及其说明，供前端编辑角色时选择
+type PermissionInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AllPermissions 所有可分配的权限
+var AllPermissions = []PermissionInfo{
+	{PermissionUsersRead, "查看用户和会话"},
+	{PermissionUsersWrite, "创建、修改、删除和停用用户，管理会话、两步验证和服务账户"},
+	{PermissionUsersResetPass, "重置用户密码"},
+	{PermissionUsersImpersonate, "模拟登录为其他用户"},
+	{PermissionRolesRead, "查看角色"},
+	{PermissionRolesWrite, "管理角色和用户的角色"},
+	{PermissionTokensRead, "查看所有 API 令牌"},
+	{PermissionTokensWrite, "吊销任意 API 令牌"},
+	{PermissionAuditRead, "查看安全事件、模拟登录记录和登录锁定"},
+	{PermissionSecurityRead, "查看 IP 访问规则和签名密钥"},
+	{PermissionSecurityWrite, "管理 IP 访问规则，轮换签名密钥"},
+	{PermissionSettingsRead, "查看系统配置"},
+	{PermissionSettingsWrite, "修改系统配置，测试 LDAP 连接"},
+	{PermissionPoliciesRead, "查看接口策略和路由，解释策略判断结果"},
+}
+
+// ValidPermission 判断权限名是否存在
+func ValidPermission(name string) bool {
+	if name == PermissionAll {
+		return true
+	}
+	for _, p := range AllPermissions {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleAdmin 内置的管理员角色，拥有全部权限，不能删除或修改权限
+const RoleAdmin = "admin"
+
+// Role 一组权限，通过 UserRole 分配给用户
+type Role struct {
+	ID          uint   `gorm:"primarykey"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	BuiltIn     bool `gorm:"default:false"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// RolePermission 角色拥有的权限
+type RolePermission struct {
+	ID         uint   `gorm:"primarykey"`
+	RoleID     uint   `gorm:"uniqueIndex:idx_role_permission;not null"`
+	Permission string `gorm:"uniqueIndex:idx_role_permission;not null"`
+}
+
+// UserRole 用户与角色的关联
+type UserRole struct {
+	ID        uint `gorm:"primarykey"`
+	UserID    uint `gorm:"uniqueIndex:idx_user_role;not null"`
+	RoleID    uint `gorm:"uniqueIndex:idx_user_role;index;not null"`
+	CreatedAt time.Time
+}
+
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	BuiltIn     bool      `json:"built_in"`
+	Permissions []string  `json:"permissions"`
+	UserCount   int64     `json:"user_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type SetUserRolesRequest struct {
+	RoleIDs []uint `json:"role_ids"`
+}
+
+// LoadUserRoles 查询并填充用户的角色名和权限
+func LoadUserRoles(db *gorm.DB, users ...*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	byID := make(map[uint]*User, len(users))
+	ids := make([]uint, 0, len(users))
+	for _, user := range users {
+		user.Roles = []string{}
+		user.RoleIDs = []uint{}
+		user.Permissions = []string{}
+		byID[user.ID] = user
+		ids = append(ids, user.ID)
+	}
+
+	var roles []struct {
+		UserID uint
+		RoleID uint
+		Name   string
+	}
+	if err := db.Table("user_roles").Select("user_roles.user_id, user_roles.role_id, roles.name").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id IN ?", ids).Order("roles.name").Scan(&roles).Error; err != nil {
+		return err
+	}
+	for _, role := range roles {
+		byID[role.UserID].Roles = append(byID[role.UserID].Roles, role.Name)
+		byID[role.UserID].RoleIDs = append(byID[role.UserID].RoleIDs, role.RoleID)
+	}
+
+	var permissions []struct {
+		UserID     uint
+		Permission string
+	}
+	if err := db.Table("user_roles").Distinct("user_roles.user_id, role_permissions.permission").
+		Joins("JOIN role_permissions ON role_permissions.role_id = user_roles.role_id").
+		Where("user_roles.user_id IN ?", ids).Order("role_permissions.permission").Scan(&permissions).Error; err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		byID[p.UserID].Permissions = append(byID[p.UserID].Permissions, p.Permission)
+	}
+	return nil
+}
+
+// AssignRole 按角色名为用户添加或移除角色，返回是否有变化；用于 LDAP、OIDC 同步管理员组
+func AssignRole(db *gorm.DB, userID uint, roleName string, assigned bool) (bool, error) {
+	var role Role
+	if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := db.Model(&UserRole{}).Where("user_id = ? AND role_id = ?", userID, role.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if (count > 0) == assigned {
+		return false, nil
+	}
+
+	if assigned {
+		return true, db.Create(&UserRole{UserID: userID, RoleID: role.ID}).Error
+	}
+	return true, db.Where("user_id = ? AND role_id = ?", userID, role.ID).Delete(&UserRole{}).Error
+}
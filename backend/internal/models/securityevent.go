@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// 安全事件类型
+const (
+	SecurityEventLoginSucceeded           = "login_succeeded"
+	SecurityEventLoginFailed              = "login_failed"
+	SecurityEventPasswordChanged          = "password_changed"
+	SecurityEventPasswordReset            = "password_reset" // 通过重置链接或管理员重置
+	SecurityEventTwoFactorEnabled         = "2fa_enabled"
+	SecurityEventTwoFactorDisabled        = "2fa_disabled" // 用户关闭或管理员重置
+	SecurityEventRecoveryCodesRegenerated = "recovery_codes_regenerated"
+	SecurityEventPasskeyAdded             = "passkey_added"
+	SecurityEventPasskeyRemoved           = "passkey_removed"
+	SecurityEventPasskeyCloneDetected     = "passkey_clone_detected" // 签名计数回退，通行密钥已停用
+	SecurityEventRoleChanged              = "role_changed"
+	SecurityEventAccountDisabled          = "account_disabled"
+	SecurityEventAccountEnabled           = "account_enabled"
+	SecurityEventIdentityLinked           = "identity_linked" // 关联单点登录账户
+)
+
+// 登录方式
+const (
+	LoginMethodPassword = "password"
+	LoginMethodLDAP     = "ldap"
+	LoginMethodTOTP     = "totp" // 两步验证的第二步
+	LoginMethodPasskey  = "passkey"
+	LoginMethodOIDC     = "oidc"
+)
+
+// SecurityEvent 登录尝试和账户安全相关操作的记录
+type SecurityEvent struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    *uint  `gorm:"index"` // 用户名不存在的登录失败为 NULL
+	Username  string `gorm:"index"` // 事件发生时的用户名或登录时输入的用户名
+	Type      string `gorm:"index;not null"`
+	Method    string // 登录方式，仅登录事件有效
+	Detail    string // 失败原因或变更说明
+	IP        string `gorm:"index"`
+	UserAgent string
+	// 由管理员代为操作时记录操作者，用户本人操作时为 NULL
+	ActorID   *uint
+	CreatedAt time.Time `gorm:"index"`
+}
+
+type SecurityEventResponse struct {
+	ID            uint      `json:"id"`
+	UserID        *uint     `json:"user_id"`
+	Username      string    `json:"username"`
+	Type          string    `json:"type"`
+	Method        string    `json:"method"`
+	Detail        string    `json:"detail"`
+	IP            string    `json:"ip"`
+	UserAgent     string    `json:"user_agent"`
+	ActorID       *uint     `json:"actor_id,omitempty"`
+	ActorUsername string    `json:"actor_username,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SecurityEventPage struct {
+	Items    []SecurityEventResponse `json:"items"`
+	Total    int64                   `json:"total"`
+	Page     int                     `json:"page"`
+	PageSize int                     `json:"page_size"`
+}
+
+// RoleChangeDetail 角色变更事件的说明
+func RoleChangeDetail(role string, assigned bool) string {
+	if assigned {
+		return "授予角色 " + role
+	}
+	return "移除角色 " + role
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Session 用户的登录会话，与同一家族的刷新令牌一一对应
+type Session struct {
+	ID         uint   `gorm:"primarykey"`
+	SessionID  string `gorm:"uniqueIndex;not null"`
+	UserID     uint   `gorm:"index;not null"`
+	IP         string
+	UserAgent  string
+	LastSeenAt time.Time
+	ExpiresAt  time.Time `gorm:"index;not null"`
+	RevokedAt  *time.Time
+	RememberMe bool // 刷新时按“记住我”的有效期顺延
+	CreatedAt  time.Time
+	// 管理员模拟登录时创建的会话记录发起模拟的管理员
+	ImpersonatorID      *uint `gorm:"index"`
+	ImpersonationReason string
+}
+
+// IsIdle 判断会话是否已连续 timeout 时间没有活动，timeout 为 0 时不限制
+func (s *Session) IsIdle(timeout time.Duration, now time.Time) bool {
+	return timeout > 0 && now.Sub(s.LastSeenAt) > timeout
+}
+
+type SessionResponse struct {
+	ID         uint      `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+	// 模拟登录会话的发起人
+	ImpersonatorID *uint `json:"impersonator_id,omitempty"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken 已吊销的令牌，登出后令牌的 jti 记录在此直到其自然过期
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	JTI       string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+}
+
+// RefreshToken 刷新令牌，仅保存哈希值；同一次登录轮换出的令牌属于同一个家族
+type RefreshToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	FamilyID  string    `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"` // Cookie 模式下可省略，从 Cookie 读取
+}
+
+// SigningKey JWT 签名密钥；RetiredAt 为空的最新密钥用于签名，轮换下来的密钥在宽限期内仍可验证
+type SigningKey struct {
+	ID        uint   `gorm:"primarykey"`
+	KID       string `gorm:"column:kid;uniqueIndex;not null"`
+	Algorithm string `gorm:"not null"`
+	Secret    string `gorm:"not null"` // HS256 为 base64 编码的密钥，其他算法为 PKCS#8 PEM 私钥
+	RetiredAt *time.Time
+	CreatedAt time.Time
+}
+
+// SigningKeyResponse 签名密钥信息，不包含私钥
+type SigningKeyResponse struct {
+	KID       string     `json:"kid"`
+	Algorithm string     `json:"algorithm"`
+	Active    bool       `json:"active"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiredAt *time.Time `json:"retired_at"`
+	ExpiresAt *time.Time `json:"expires_at"` // 宽限期结束时间，之后不再接受该密钥签发的令牌
+}
+
+type RotateSigningKeyRequest struct {
+	Algorithm string `json:"algorithm"` // 为空时使用配置的算法
+}
@@ -1,28 +1,66 @@
 package models
 
 import (
-	"time"
 	"gorm.io/gorm"
+	"time"
+)
+
+// 用户来源
+const (
+	UserSourceLocal = "local"
+	UserSourceLDAP  = "ldap"
+	UserSourceOIDC  = "oidc"
+)
+
+// 账户类型
+const (
+	AccountTypeUser    = "user"
+	AccountTypeService = "service" // 服务账户，不能登录，只能持有 API 凭据
 )
 
 type User struct {
-	ID        uint           `gorm:"primarykey"`
-	Username  string         `gorm:"uniqueIndex;not null"`
-	Password  string         `gorm:"not null"`
-	IsAdmin   bool          `gorm:"default:false"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID                  uint    `gorm:"primarykey"`
+	Username            string  `gorm:"uniqueIndex;not null"`
+	Password            string  `gorm:"not null"`
+	Email               *string `gorm:"uniqueIndex"` // 用于接收密码重置等邮件，未设置时为 NULL
+	EmailVerifiedAt     *time.Time
+	PendingVerification bool       `gorm:"default:false"`          // 注册后等待邮箱验证，验证前不能登录
+	Source              string     `gorm:"default:local;not null"` // 外部身份源的用户不能使用本地密码登录
+	AccountType         string     `gorm:"default:user;not null;index"`
+	TOTPSecret          string     // 两步验证密钥，启用前为待确认的密钥
+	TOTPEnabled         bool       `gorm:"default:false"`
+	TOTPLastStep        int64      // 最近一次通过验证的时间步，用于拒绝验证码重放
+	MustChangePassword  bool       `gorm:"default:false"` // 为 true 时必须先修改密码才能使用其他功能
+	DisabledAt          *time.Time // 停用时间，为 NULL 时账户正常
+	DisabledReason      string
+	ExpiresAt           *time.Time `gorm:"index"` // 账户到期时间，到期后自动停用
+	LastLoginAt         *time.Time // 最近一次成功登录
+	LastLoginIP         string
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletedAt           gorm.DeletedAt `gorm:"index"`
+
+	// 由 LoadUserRoles 填充，不保存在 users 表中
+	Roles       []string `gorm:"-"`
+	RoleIDs     []uint   `gorm:"-"`
+	Permissions []string `gorm:"-"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username string `json:"username" binding:"required"` // 用户名或邮箱
+
+	Password      string `json:"password" binding:"required"`
+	RememberMe    bool   `json:"remember_me"` // 使用较长的会话有效期
+	CaptchaID     string `json:"captcha_id"`  // 连续失败后需要验证码
+	CaptchaAnswer string `json:"captcha_answer"`
 }
 
 type RegisterRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username      string `json:"username" binding:"required"`
+	Password      string `json:"password" binding:"required"`
+	Email         string `json:"email" binding:"omitempty,email"`
+	CaptchaID     string `json:"captcha_id"` // 自助注册时必填，管理员创建用户时忽略
+	CaptchaAnswer string `json:"captcha_answer"`
 }
 
 type UpdatePasswordRequest struct {
@@ -31,9 +69,106 @@ type UpdatePasswordRequest struct {
 }
 
 type UserResponse struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	IsAdmin   bool      `json:"is_admin"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-} 
\ No newline at end of file
+	ID                 uint       `json:"id"`
+	Username           string     `json:"username"`
+	Email              string     `json:"email"`
+	EmailVerified      bool       `json:"email_verified"`
+	IsAdmin            bool       `json:"is_admin"` // 拥有任一管理权限，可以进入管理后台
+	Roles              []string   `json:"roles"`
+	Permissions        []string   `json:"permissions"`
+	Source             string     `json:"source"`
+	AccountType        string     `json:"account_type"`
+	MustChangePassword bool       `json:"must_change_password"`
+	Disabled           bool       `json:"disabled"`
+	DisabledAt         *time.Time `json:"disabled_at"`
+	DisabledReason     string     `json:"disabled_reason"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+	LastLoginAt        *time.Time `json:"last_login_at"`
+	LastLoginIP        string     `json:"last_login_ip"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	// 当前处于模拟登录时为实际操作的管理员，用于前端显示提示
+	Impersonator *ImpersonatorInfo `json:"impersonator,omitempty"`
+}
+
+// IsDisabled 判断账户是否已停用或已到期
+func (u *User) IsDisabled(now time.Time) bool {
+	return u.DisabledAt != nil || (u.ExpiresAt != nil && !u.ExpiresAt.After(now))
+}
+
+// HasPermission 判断用户的角色是否包含指定权限，需先调用 LoadUserRoles
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == PermissionAll || p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin 判断用户是否拥有任一管理权限，需先调用 LoadUserRoles
+func (u *User) IsAdmin() bool {
+	return len(u.Permissions) > 0
+}
+
+// IsServiceAccount 判断是否为服务账户
+func (u *User) IsServiceAccount() bool {
+	return u.AccountType == AccountTypeService
+}
+
+type DisableUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SetUserExpiryRequest 设置账户到期时间，为 null 时取消到期
+type SetUserExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name    string `json:"name" binding:"required"`
+	RoleIDs []uint `json:"role_ids"`
+}
+
+// PasswordHistory 用户曾经使用过的密码哈希，用于禁止重复使用
+type PasswordHistory struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"index;not null"`
+	PasswordHash string `gorm:"not null"`
+	CreatedAt    time.Time
+}
+
+// PasswordResetToken 一次性密码重置令牌，仅保存哈希值
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primarykey"`
+	UserID    uint      `gorm:"index;not null"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// LDAPTestRequest 管理员测试 LDAP 连接，填写用户名时同时测试用户查找，填写密码时测试用户绑定
+type LDAPTestRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResetUserPasswordRequest struct {
+	// Mode 为 password 时生成临时密码，为 link 时生成一次性重置链接
+	Mode string `json:"mode"`
+}
+
+// ForgotPasswordRequest 用户名或邮箱均可用于找回密码
+type ForgotPasswordRequest struct {
+	Login string `json:"login" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
@@ -0,0 +1,78 @@
+// Package optioncache 缓存中间件在每个请求中读取的系统配置，options 表被写入时自动失效
+package optioncache
+
+import (
+	"log"
+	"strconv"
+
+	"backend/internal/database"
+	"backend/internal/models"
+	"backend/internal/snapshot"
+
+	"gorm.io/gorm"
+)
+
+// Cache 进程内的配置缓存
+type Cache struct {
+	values *snapshot.Cache[map[string]string]
+}
+
+// New 创建配置缓存，并注册 GORM 回调：options 表的任何写入都会使缓存失效
+func New(db *gorm.DB) *Cache {
+	c := &Cache{values: snapshot.New(func() (map[string]string, error) {
+		var options []models.Option
+		if err := db.Find(&options).Error; err != nil {
+			return nil, err
+		}
+		values := make(map[string]string, len(options))
+		for _, option := range options {
+			values[option.OptionName] = option.OptionValue
+		}
+		return values, nil
+	})}
+
+	db.Callback().Create().After("gorm:create").Register("optioncache:invalidate", c.invalidateCallback)
+	db.Callback().Update().After("gorm:update").Register("optioncache:invalidate", c.invalidateCallback)
+	db.Callback().Delete().After("gorm:delete").Register("optioncache:invalidate", c.invalidateCallback)
+	return c
+}
+
+// Value 读取配置项的值，配置项不存在或读取失败时返回默认值，与 models.GetOptionValue 一致
+func (c *Cache) Value(name string) string {
+	values, err := c.values.Get()
+	if err != nil {
+		log.Printf("Failed to load options: %v", err)
+	}
+	if value, ok := values[name]; ok {
+		return value
+	}
+	for _, option := range models.DefaultOptions {
+		if option.OptionName == name {
+			return option.OptionValue
+		}
+	}
+	return ""
+}
+
+// Int 读取整数配置项，无法解析时返回 fallback
+func (c *Cache) Int(name string, fallback int) int {
+	value, err := strconv.Atoi(c.Value(name))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// Invalidate 清除缓存，下次读取时重新加载
+func (c *Cache) Invalidate() {
+	c.values.Invalidate()
+}
+
+// invalidateCallback 在事务中时立即清除一次，使并发的加载作废，事务提交后再清除一次，丢弃提交前读到的旧数据
+func (c *Cache) invalidateCallback(db *gorm.DB) {
+	if db.Statement.Schema == nil || db.Statement.Schema.Table != "options" {
+		return
+	}
+	c.Invalidate()
+	database.AfterCommit(db, c.Invalidate)
+}
@@ -0,0 +1,99 @@
+package optioncache_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/models"
+	"backend/internal/optioncache"
+
+	"gorm.io/gorm"
+)
+
+func newTestCache(t *testing.T) (*gorm.DB, *optioncache.Cache, *atomic.Int32) {
+	t.Helper()
+	t.Setenv("DATA_PATH", t.TempDir())
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// 统计 options 表的查询次数
+	var queries atomic.Int32
+	db.Callback().Query().After("gorm:query").Register("test:count", func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "options" {
+			queries.Add(1)
+		}
+	})
+	return db, optioncache.New(db), &queries
+}
+
+func TestValueIsCached(t *testing.T) {
+	_, cache, queries := newTestCache(t)
+
+	for i := 0; i < 3; i++ {
+		if got := cache.Int(models.OptionSessionIdleTimeoutMinutes, -1); got != 0 {
+			t.Fatalf("idle timeout = %d, want default 0", got)
+		}
+		if got := cache.Value(models.OptionRequireAdmin2FA); got != "false" {
+			t.Fatalf("require admin 2FA = %q", got)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Fatalf("options loaded %d times, want 1", n)
+	}
+}
+
+func TestWritesInvalidate(t *testing.T) {
+	db, cache, _ := newTestCache(t)
+	cache.Value(models.OptionSessionIdleTimeoutMinutes)
+
+	if err := db.Model(&models.Option{}).Where("option_name = ?", models.OptionSessionIdleTimeoutMinutes).Update("option_value", "30").Error; err != nil {
+		t.Fatalf("update option: %v", err)
+	}
+	if got := cache.Int(models.OptionSessionIdleTimeoutMinutes, 0); got != 30 {
+		t.Fatalf("after update: %d, want 30", got)
+	}
+
+	if err := db.Unscoped().Where("option_name = ?", models.OptionSessionIdleTimeoutMinutes).Delete(&models.Option{}).Error; err != nil {
+		t.Fatalf("delete option: %v", err)
+	}
+	if got := cache.Int(models.OptionSessionIdleTimeoutMinutes, -1); got != 0 {
+		t.Fatalf("deleted option should fall back to the default, got %d", got)
+	}
+
+	if err := db.Create(&models.Option{OptionName: models.OptionSessionIdleTimeoutMinutes, OptionValue: "5"}).Error; err != nil {
+		t.Fatalf("create option: %v", err)
+	}
+	if got := cache.Int(models.OptionSessionIdleTimeoutMinutes, 0); got != 5 {
+		t.Fatalf("after create: %d, want 5", got)
+	}
+}
+
+func TestTransactionInvalidatesAfterCommit(t *testing.T) {
+	db, cache, _ := newTestCache(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Option{}).Where("option_name = ?", models.OptionRequireAdmin2FA).Update("option_value", "true").Error; err != nil {
+			return err
+		}
+		// 提交前在事务外读取，只能读到旧值
+		if got := cache.Value(models.OptionRequireAdmin2FA); got != "false" {
+			t.Errorf("uncommitted value visible: %q", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+	if got := cache.Value(models.OptionRequireAdmin2FA); got != "true" {
+		t.Fatalf("value read before commit was cached: %q", got)
+	}
+}
@@ -0,0 +1,193 @@
+// Package password 实现可配置的密码安全策略
+package password
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxBytes bcrypt 只处理前 72 字节，超出部分会被静默忽略
+const MaxBytes = 72
+
+// 策略规则名称
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleLowercase = "lowercase"
+	RuleUppercase = "uppercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleUsername  = "username"
+	RuleCommon    = "common"
+	RuleReused    = "reused"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = loadCommonPasswords()
+
+func loadCommonPasswords() map[string]struct{} {
+	passwords := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswordList))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			passwords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return passwords
+}
+
+// Policy 密码策略
+type Policy struct {
+	MinLength        int
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	ForbidUsername   bool
+	RejectCommon     bool
+	HistoryCount     int // 不允许与最近 N 个密码相同，由调用方检查
+}
+
+// Violation 一条未通过的策略规则
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError 密码未通过策略时返回，列出全部未通过的规则
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "；")
+}
+
+// Validate 检查密码是否符合策略，reused 表示密码与历史密码相同
+func (p Policy) Validate(password, username string, reused bool) error {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	if length := len([]rune(password)); length < p.MinLength {
+		add(RuleMinLength, "密码长度不能少于"+strconv.Itoa(p.MinLength)+"个字符")
+	}
+	if len(password) > MaxBytes {
+		add(RuleMaxLength, "密码长度不能超过"+strconv.Itoa(MaxBytes)+"字节")
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireLowercase && !hasLower {
+		add(RuleLowercase, "密码必须包含小写字母")
+	}
+	if p.RequireUppercase && !hasUpper {
+		add(RuleUppercase, "密码必须包含大写字母")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(RuleDigit, "密码必须包含数字")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "密码必须包含特殊字符")
+	}
+
+	lower := strings.ToLower(password)
+	if p.ForbidUsername && username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		add(RuleUsername, "密码不能包含用户名")
+	}
+	if p.RejectCommon {
+		if _, ok := commonPasswords[lower]; ok {
+			add(RuleCommon, "密码过于常见")
+		}
+	}
+	if reused {
+		add(RuleReused, "不能使用最近"+strconv.Itoa(p.HistoryCount)+"次使用过的密码")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// generateAttempts 随机密码偶尔会包含用户名或过于简单，重新生成的次数上限
+const generateAttempts = 20
+
+// Generate 生成符合策略的随机密码，长度取 length 与策略最小长度中较大者；
+// 密码总是包含全部字符类，因此满足任意字符类要求
+func (p Policy) Generate(length int, username string) (string, error) {
+	if length < p.MinLength {
+		length = p.MinLength
+	}
+	for i := 0; i < generateAttempts; i++ {
+		password, err := Generate(length)
+		if err != nil {
+			return "", err
+		}
+		if p.Validate(password, username, false) == nil {
+			return password, nil
+		}
+	}
+	return "", errors.New("password: cannot generate a password satisfying the policy")
+}
+
+// Generate 生成包含大小写字母、数字和特殊字符的随机密码
+func Generate(length int) (string, error) {
+	const (
+		lower   = "abcdefghijkmnpqrstuvwxyz"
+		upper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits  = "23456789"
+		symbols = "!@#$%^&*-_=+?"
+	)
+	classes := []string{lower, upper, digits, symbols}
+	all := strings.Join(classes, "")
+
+	result := make([]byte, length)
+	for i := range result {
+		// 前几位依次取自每个字符类，保证满足任意字符类要求
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		result[i] = charset[n.Int64()]
+	}
+
+	// 打乱顺序，避免字符类位置固定
+	for i := len(result) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result), nil
+}
@@ -0,0 +1,41 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyGenerate(t *testing.T) {
+	policy := Policy{
+		MinLength:        24,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		ForbidUsername:   true,
+		RejectCommon:     true,
+	}
+	for i := 0; i < 100; i++ {
+		// 单字母用户名约有一半概率出现在随机密码中，需要重新生成
+		generated, err := policy.Generate(16, "k")
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if len(generated) != 24 {
+			t.Fatalf("expected the policy minimum length, got %d", len(generated))
+		}
+		if err := policy.Validate(generated, "k", false); err != nil {
+			t.Fatalf("generated password %q violates the policy: %v", generated, err)
+		}
+		if strings.Contains(strings.ToLower(generated), "k") {
+			t.Fatalf("generated password %q contains the username", generated)
+		}
+	}
+}
+
+func TestPolicyGenerateImpossible(t *testing.T) {
+	policy := Policy{MinLength: MaxBytes + 1}
+	if _, err := policy.Generate(16, ""); err == nil {
+		t.Fatal("expected an error for a policy no password can satisfy")
+	}
+}
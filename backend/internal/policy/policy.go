@@ -0,0 +1,150 @@
+// Package policy 按数据库中的接口策略控制用户对路由的访问，策略在进程内缓存，修改后需调用 Invalidate
+package policy
+
+import (
+	"strings"
+
+	"backend/internal/models"
+	"backend/internal/snapshot"
+
+	"gorm.io/gorm"
+)
+
+// 策略的判断结果，DecisionNone 表示未命中任何策略，由角色权限决定
+const (
+	DecisionAllow = models.PolicyAllow
+	DecisionDeny  = models.PolicyDeny
+	DecisionNone  = "none"
+)
+
+// Result 策略的判断结果
+type Result struct {
+	Decision string
+	Policy   *models.Policy  // 决定结果的策略，未命中时为 nil
+	Matched  []models.Policy // 所有命中的策略
+}
+
+// Engine 缓存全部接口策略
+type Engine struct {
+	policies *snapshot.Cache[[]models.Policy]
+}
+
+// New 创建策略引擎，策略在第一次检查时加载
+func New(db *gorm.DB) *Engine {
+	return &Engine{policies: snapshot.New(func() ([]models.Policy, error) {
+		var policies []models.Policy
+		err := db.Order("id").Find(&policies).Error
+		return policies, err
+	})}
+}
+
+// Invalidate 策略变更后清除缓存，下次检查时重新加载
+func (e *Engine) Invalidate() {
+	e.policies.Invalidate()
+}
+
+// Check 判断用户能否以 method 访问路由 route，route 为 gin 注册的路由而不是请求的路径
+func (e *Engine) Check(user *models.User, method, route string) (Result, error) {
+	policies, err := e.policies.Get()
+	if err != nil {
+		return Result{}, err
+	}
+	return Evaluate(policies, user, method, route), nil
+}
+
+// Evaluate 使用给定的策略集判断，命中 deny 策略即拒绝，否则命中 allow 策略即允许；
+// 用于在保存策略前判断是否会拒绝当前用户
+func Evaluate(policies []models.Policy, user *models.User, method, route string) Result {
+	result := Result{Decision: DecisionNone, Matched: []models.Policy{}}
+	for i := range policies {
+		p := &policies[i]
+		if !matchSubject(p, user) || (p.Method != models.PolicyMethodAny && p.Method != method) || !MatchPath(p.Path, route) {
+			continue
+		}
+		result.Matched = append(result.Matched, *p)
+		switch {
+		case p.Effect == models.PolicyDeny && result.Decision != DecisionDeny:
+			result.Decision, result.Policy = DecisionDeny, p
+		case p.Effect == models.PolicyAllow && result.Decision == DecisionNone:
+			result.Decision, result.Policy = DecisionAllow, p
+		}
+	}
+	if result.Policy != nil {
+		decided := *result.Policy
+		result.Policy = &decided
+	}
+	return result
+}
+
+func matchSubject(p *models.Policy, user *models.User) bool {
+	switch p.Subject {
+	case models.PolicySubjectAll:
+		return true
+	case models.PolicySubjectUser:
+		return p.UserID != nil && *p.UserID == user.ID
+	case models.PolicySubjectRole:
+		if p.RoleID == nil {
+			return false
+		}
+		for _, id := range user.RoleIDs {
+			if id == *p.RoleID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatchPath 判断策略的路径模式是否匹配路由：* 匹配全部，以 /* 结尾时按前缀匹配，否则必须与路由完全相同
+func MatchPath(pattern, route string) bool {
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "/*"):
+		return strings.HasPrefix(route, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == route
+	}
+}
+
+// ResolveRoute 找出请求路径 path 对应的路由，routes 为 gin 注册的路由；
+// 多个路由都匹配时与 gin 一样优先选择静态片段更多的路由
+func ResolveRoute(routes []string, path string) (string, bool) {
+	best, bestStatic := "", -1
+	for _, route := range routes {
+		if route == path {
+			return route, true
+		}
+		if static, ok := matchRoute(route, path); ok && static > bestStatic {
+			best, bestStatic = route, static
+		}
+	}
+	return best, bestStatic >= 0
+}
+
+// matchRoute 按 gin 的规则匹配路由，:name 匹配一个片段，*name 匹配剩余部分；返回匹配的静态片段数
+func matchRoute(route, path string) (int, bool) {
+	routeParts := strings.Split(strings.Trim(route, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+
+	static := 0
+	for i, part := range routeParts {
+		if strings.HasPrefix(part, "*") {
+			return static, true
+		}
+		if i >= len(pathParts) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(part, ":"):
+			if pathParts[i] == "" {
+				return 0, false
+			}
+		case part == pathParts[i]:
+			static++
+		default:
+			return 0, false
+		}
+	}
+	return static, len(routeParts) == len(pathParts)
+}
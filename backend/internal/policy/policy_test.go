@@ -0,0 +1,152 @@
+package policy_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/models"
+	"backend/internal/policy"
+
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	t.Setenv("DATA_PATH", t.TempDir())
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func uintPtr(v uint) *uint {
+	return &v
+}
+
+func TestEvaluate(t *testing.T) {
+	user := &models.User{ID: 7, RoleIDs: []uint{3}}
+	policies := []models.Policy{
+		{ID: 1, Subject: models.PolicySubjectAll, Method: models.PolicyMethodAny, Path: "/api/admin/users/*", Effect: models.PolicyAllow},
+		{ID: 2, Subject: models.PolicySubjectRole, RoleID: uintPtr(3), Method: "DELETE", Path: "/api/admin/users/:id", Effect: models.PolicyDeny},
+		{ID: 3, Subject: models.PolicySubjectUser, UserID: uintPtr(8), Method: models.PolicyMethodAny, Path: "*", Effect: models.PolicyDeny},
+		{ID: 4, Subject: models.PolicySubjectUser, UserID: uintPtr(7), Method: "GET", Path: "/api/admin/roles", Effect: models.PolicyAllow},
+	}
+
+	tests := []struct {
+		method, route string
+		decision      string
+		policyID      uint
+		matched       int
+	}{
+		{"GET", "/api/admin/users/:id", policy.DecisionAllow, 1, 1},
+		{"DELETE", "/api/admin/users/:id", policy.DecisionDeny, 2, 2}, // deny 优先于 allow
+		{"GET", "/api/admin/roles", policy.DecisionAllow, 4, 1},
+		{"POST", "/api/admin/roles", policy.DecisionNone, 0, 0},
+		{"GET", "/api/admin/options", policy.DecisionNone, 0, 0}, // 其他用户的策略不生效
+	}
+	for _, tt := range tests {
+		result := policy.Evaluate(policies, user, tt.method, tt.route)
+		if result.Decision != tt.decision || len(result.Matched) != tt.matched {
+			t.Errorf("%s %s: got %s with %d matched, want %s with %d", tt.method, tt.route, result.Decision, len(result.Matched), tt.decision, tt.matched)
+			continue
+		}
+		if tt.policyID != 0 && (result.Policy == nil || result.Policy.ID != tt.policyID) {
+			t.Errorf("%s %s: decided by %+v, want policy %d", tt.method, tt.route, result.Policy, tt.policyID)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, route string
+		want           bool
+	}{
+		{"*", "/api/user", true},
+		{"/api/admin/*", "/api/admin/users/:id", true},
+		{"/api/admin/*", "/api/administrators", false},
+		{"/api/admin/users", "/api/admin/users", true},
+		{"/api/admin/users", "/api/admin/users/:id", false},
+	}
+	for _, tt := range tests {
+		if got := policy.MatchPath(tt.pattern, tt.route); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.route, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRoute(t *testing.T) {
+	routes := []string{"/api/admin/users/:id", "/api/admin/users/:id/sessions", "/api/admin/policies/explain", "/api/admin/policies/:id", "/assets/*filepath"}
+	tests := []struct {
+		path, want string
+		ok         bool
+	}{
+		{"/api/admin/users/5", "/api/admin/users/:id", true},
+		{"/api/admin/users/5/sessions", "/api/admin/users/:id/sessions", true},
+		{"/api/admin/policies/explain", "/api/admin/policies/explain", true}, // 静态路由优先
+		{"/api/admin/policies/3", "/api/admin/policies/:id", true},
+		{"/assets/js/app.js", "/assets/*filepath", true},
+		{"/api/admin/users", "", false},
+		{"/api/admin/users/5/roles", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := policy.ResolveRoute(routes, tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ResolveRoute(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckUsesCachedPolicies(t *testing.T) {
+	db := newTestDB(t)
+	engine := policy.New(db)
+	user := &models.User{ID: 7}
+
+	if result, err := engine.Check(user, "GET", "/api/user"); err != nil || result.Decision != policy.DecisionNone {
+		t.Fatalf("no policies: %+v, %v", result, err)
+	}
+	deny := models.Policy{Subject: models.PolicySubjectAll, Method: models.PolicyMethodAny, Path: "*", Effect: models.PolicyDeny}
+	if err := db.Create(&deny).Error; err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+	if result, _ := engine.Check(user, "GET", "/api/user"); result.Decision != policy.DecisionNone {
+		t.Fatalf("policies should be cached until Invalidate, got %s", result.Decision)
+	}
+	engine.Invalidate()
+	if result, _ := engine.Check(user, "GET", "/api/user"); result.Decision != policy.DecisionDeny {
+		t.Fatalf("new policy should apply after Invalidate, got %s", result.Decision)
+	}
+}
+
+func TestCheckDropsLoadInvalidatedConcurrently(t *testing.T) {
+	db := newTestDB(t)
+	engine := policy.New(db)
+	user := &models.User{ID: 7}
+
+	// 在加载读取数据库后、写入缓存前新增策略并调用 Invalidate，模拟并发的策略修改
+	var invalidate atomic.Bool
+	db.Callback().Query().After("gorm:query").Register("test:invalidate", func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "policies" && invalidate.CompareAndSwap(true, false) {
+			deny := models.Policy{Subject: models.PolicySubjectAll, Method: models.PolicyMethodAny, Path: "*", Effect: models.PolicyDeny}
+			if err := db.Create(&deny).Error; err != nil {
+				t.Errorf("create policy: %v", err)
+			}
+			engine.Invalidate()
+		}
+	})
+
+	invalidate.Store(true)
+	if result, err := engine.Check(user, "GET", "/api/user"); err != nil || result.Decision != policy.DecisionNone {
+		t.Fatalf("in-flight load: %+v, %v", result, err)
+	}
+	if result, _ := engine.Check(user, "GET", "/api/user"); result.Decision != policy.DecisionDeny {
+		t.Fatalf("load invalidated during Check must not be cached, got %s", result.Decision)
+	}
+}
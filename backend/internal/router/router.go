@@ -1,52 +1,153 @@
 package router
 
 import (
+	"log"
+	"time"
+
 	"backend/internal/config"
 	"backend/internal/handlers"
+	"backend/internal/ipfilter"
+	"backend/internal/keyring"
 	"backend/internal/middleware"
+	"backend/internal/models"
+	"backend/internal/optioncache"
+	"backend/internal/policy"
+	"backend/internal/usercache"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB, cfg *config.Config) *gin.Engine {
+// userCacheTTL 用户缓存有效期，作为回调失效之外的兜底
+const userCacheTTL = 10 * time.Second
+
+func SetupRouter(db *gorm.DB, cfg *config.Config, keys *keyring.Keyring) *gin.Engine {
 	r := gin.Default()
 
-	// 创建处理器
-	h := handlers.NewHandler(db, cfg)
+	// 只信任配置的代理转发的客户端地址，否则任何人都能通过 X-Forwarded-For 伪造来源 IP
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Invalid trusted proxies, ignoring forwarded headers: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+
+	// 基于 CIDR 的访问规则
+	ips := ipfilter.New(db)
+
+	// 按请求方法和路由授予或拒绝访问的接口策略
+	policies := policy.New(db)
+
+	// 创建处理器，路由在下面注册，列出路由时才读取
+	h := handlers.NewHandler(db, cfg, keys, ips, policies)
+	h.SetRoutes(r.Routes)
+
+	// 认证中间件使用的用户缓存，用户被修改或删除时自动失效
+	users := usercache.New(db, userCacheTTL)
+
+	// 中间件每个请求都要读取的系统配置，修改配置时自动失效
+	options := optioncache.New(db)
 
 	// 允许跨域
-	r.Use(middleware.CORS())
+	r.Use(middleware.CORS(cfg))
+
+	// 签名公钥，供其他服务验证令牌
+	r.GET("/.well-known/jwks.json", h.JWKS)
 
 	// 公开路由
 	public := r.Group("/api")
+	public.Use(middleware.IPFilter(ips, models.IPRuleScopePublic))
 	{
 		public.POST("/login", h.Login)
+		public.POST("/login/2fa", h.VerifyTwoFactorLogin)
+		public.POST("/login/passkey/begin", h.BeginPasskeyLogin)
+		public.POST("/login/passkey/finish", h.FinishPasskeyLogin)
 		public.GET("/sysinfo", h.GetSysInfo)
+		public.GET("/captcha", h.GetCaptcha)
 		public.POST("/register", h.Register)
+		public.POST("/token/refresh", h.RefreshToken)
+		public.GET("/oidc/login", h.BeginOIDCLogin)
+		public.GET("/oidc/callback", h.OIDCCallback)
+		public.POST("/oidc/exchange", h.ExchangeOIDCCode)
+		public.POST("/password/forgot", h.ForgotPassword)
+		public.POST("/password/reset", h.ResetPassword)
+		public.POST("/email/verify", h.VerifyEmail)
 	}
 
 	// 需要认证的路由
 	auth := r.Group("/api")
-	auth.Use(middleware.Auth(cfg.JWTSecret))
+	auth.Use(middleware.IPFilter(ips, models.IPRuleScopeAuth), middleware.Auth(db, cfg, keys, users, options), middleware.UserIPFilter(ips), middleware.Policy(policies))
 	{
 		auth.GET("/user", h.GetUserInfo)
 		auth.PUT("/user/password", h.UpdatePassword)
 		auth.POST("/logout", h.Logout)
+		auth.GET("/user/sessions", h.ListSessions)
+		auth.DELETE("/user/sessions", h.RevokeOtherSessions)
+		auth.DELETE("/user/sessions/:id", h.RevokeSession)
+		auth.GET("/user/login-history", h.GetLoginHistory)
+		auth.GET("/user/2fa", h.GetTwoFactorStatus)
+		auth.POST("/user/2fa/setup", h.SetupTwoFactor)
+		auth.POST("/user/2fa/enable", h.EnableTwoFactor)
+		auth.POST("/user/2fa/disable", h.DisableTwoFactor)
+		auth.POST("/user/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+		auth.GET("/user/passkeys", h.ListPasskeys)
+		auth.POST("/user/passkeys/register/begin", h.BeginPasskeyRegistration)
+		auth.POST("/user/passkeys/register/finish", h.FinishPasskeyRegistration)
+		auth.PUT("/user/passkeys/:id", h.RenamePasskey)
+		auth.DELETE("/user/passkeys/:id", h.DeletePasskey)
+		auth.POST("/user/oidc/link", h.BeginOIDCLink)
+		auth.GET("/user/tokens", h.ListAPITokens)
+		auth.POST("/user/tokens", h.CreateAPIToken)
+		auth.DELETE("/user/tokens/:id", h.RevokeAPIToken)
 	}
 
-	// 管理员路由
+	// 管理员路由：AdminOnly 要求拥有任一管理权限，每个接口再单独声明所需的权限；命中 allow 策略时两者都跳过
 	admin := r.Group("/api/admin")
-	admin.Use(middleware.Auth(cfg.JWTSecret), middleware.AdminOnly())
+	admin.Use(middleware.IPFilter(ips, models.IPRuleScopeAdmin), middleware.Auth(db, cfg, keys, users, options), middleware.UserIPFilter(ips), middleware.Policy(policies), middleware.AdminOnly(options))
 	{
-		admin.GET("/users", h.ListUsers)
-		admin.POST("/users", h.CreateUser)
-		admin.PUT("/users/:id", h.UpdateUser)
-		admin.DELETE("/users/:id", h.DeleteUser)
-		admin.POST("/users/:id/reset-password", h.ResetUserPassword)
-		admin.GET("/options", h.GetOptions)
-		admin.GET("/options/:name", h.GetOption)
-		admin.PUT("/options", h.UpdateOptions)
+		perm := middleware.RequirePermission
+
+		admin.GET("/users", perm(models.PermissionUsersRead), h.ListUsers)
+		admin.POST("/users", perm(models.PermissionUsersWrite), h.CreateUser)
+		admin.PUT("/users/:id", perm(models.PermissionUsersWrite), h.UpdateUser)
+		admin.DELETE("/users/:id", perm(models.PermissionUsersWrite), h.DeleteUser)
+		admin.POST("/users/:id/reset-password", perm(models.PermissionUsersResetPass), h.ResetUserPassword)
+		admin.GET("/users/:id/sessions", perm(models.PermissionUsersRead), h.ListUserSessions)
+		admin.DELETE("/users/:id/sessions", perm(models.PermissionUsersWrite), h.RevokeUserSessions)
+		admin.DELETE("/users/:id/2fa", perm(models.PermissionUsersWrite), h.ResetUserTwoFactor)
+		admin.POST("/users/:id/disable", perm(models.PermissionUsersWrite), h.DisableUser)
+		admin.POST("/users/:id/enable", perm(models.PermissionUsersWrite), h.EnableUser)
+		admin.PUT("/users/:id/expiry", perm(models.PermissionUsersWrite), h.SetUserExpiry)
+		admin.PUT("/users/:id/roles", perm(models.PermissionRolesWrite), h.SetUserRoles)
+		admin.POST("/users/:id/impersonate", perm(models.PermissionUsersImpersonate), h.Impersonate)
+		admin.GET("/impersonations", perm(models.PermissionAuditRead), h.ListImpersonationAudits)
+		admin.POST("/service-accounts", perm(models.PermissionUsersWrite), h.CreateServiceAccount)
+		admin.GET("/service-accounts/:id/credentials", perm(models.PermissionUsersRead), h.ListServiceAccountCredentials)
+		admin.POST("/service-accounts/:id/credentials", perm(models.PermissionUsersWrite), h.CreateServiceAccountCredential)
+		admin.POST("/service-accounts/:id/credentials/rotate", perm(models.PermissionUsersWrite), h.RotateServiceAccountCredentials)
+		admin.GET("/roles", perm(models.PermissionRolesRead), h.ListRoles)
+		admin.POST("/roles", perm(models.PermissionRolesWrite), h.CreateRole)
+		admin.PUT("/roles/:id", perm(models.PermissionRolesWrite), h.UpdateRole)
+		admin.DELETE("/roles/:id", perm(models.PermissionRolesWrite), h.DeleteRole)
+		admin.GET("/permissions", perm(models.PermissionRolesRead), h.ListPermissions)
+		admin.GET("/tokens", perm(models.PermissionTokensRead), h.ListAllAPITokens)
+		admin.DELETE("/tokens/:id", perm(models.PermissionTokensWrite), h.AdminRevokeAPIToken)
+		admin.GET("/ip-rules", perm(models.PermissionSecurityRead), h.ListIPRules)
+		admin.POST("/ip-rules", perm(models.PermissionSecurityWrite), h.CreateIPRule)
+		admin.DELETE("/ip-rules/:id", perm(models.PermissionSecurityWrite), h.DeleteIPRule)
+		admin.GET("/security-events", perm(models.PermissionAuditRead), h.ListSecurityEvents)
+		admin.GET("/lockouts", perm(models.PermissionAuditRead), h.ListLoginThrottles)
+		admin.DELETE("/lockouts/:id", perm(models.PermissionUsersWrite), h.ClearLoginThrottle)
+		admin.POST("/ldap/test", perm(models.PermissionSettingsWrite), h.TestLDAPConnection)
+		admin.GET("/keys", perm(models.PermissionSecurityRead), h.ListSigningKeys)
+		admin.POST("/keys/rotate", perm(models.PermissionSecurityWrite), h.RotateSigningKey)
+		admin.GET("/options", perm(models.PermissionSettingsRead), h.GetOptions)
+		admin.GET("/options/:name", perm(models.PermissionSettingsRead), h.GetOption)
+		admin.PUT("/options", perm(models.PermissionSettingsWrite), h.UpdateOptions)
+		admin.GET("/routes", perm(models.PermissionPoliciesRead), h.ListRoutes)
+		admin.GET("/policies", perm(models.PermissionPoliciesRead), h.ListPolicies)
+		admin.GET("/policies/explain", perm(models.PermissionPoliciesRead), h.ExplainPolicy)
+		admin.POST("/policies", perm(models.PermissionAll), h.CreatePolicy)
+		admin.PUT("/policies/:id", perm(models.PermissionAll), h.UpdatePolicy)
+		admin.DELETE("/policies/:id", perm(models.PermissionAll), h.DeletePolicy)
 	}
 
 	return r
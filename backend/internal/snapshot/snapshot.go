@@ -0,0 +1,52 @@
+// Package snapshot 缓存从数据库整体加载的数据（如访问规则、接口策略），修改后调用 Invalidate 重新加载
+package snapshot
+
+import "sync"
+
+// Cache 缓存 load 的结果，第一次 Get 时加载
+type Cache[T any] struct {
+	load func() (T, error)
+
+	mu     sync.RWMutex
+	value  T
+	loaded bool
+	// 失效计数：加载期间调用了 Invalidate 时丢弃加载到的可能已过期的数据
+	generation uint64
+}
+
+// New 创建缓存，load 从数据库加载全部数据
+func New[T any](load func() (T, error)) *Cache[T] {
+	return &Cache[T]{load: load}
+}
+
+// Get 返回缓存的数据，未加载或已失效时调用 load 加载
+func (c *Cache[T]) Get() (T, error) {
+	c.mu.RLock()
+	value, loaded, generation := c.value, c.loaded, c.generation
+	c.mu.RUnlock()
+	if loaded {
+		return value, nil
+	}
+
+	value, err := c.load()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.value, c.loaded = value, true
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// Invalidate 清除缓存，下次 Get 时重新加载；正在进行的加载结果不会被缓存
+func (c *Cache[T]) Invalidate() {
+	c.mu.Lock()
+	var zero T
+	c.value, c.loaded = zero, false
+	c.generation++
+	c.mu.Unlock()
+}
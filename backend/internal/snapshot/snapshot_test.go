@@ -0,0 +1,66 @@
+package snapshot_test
+
+import (
+	"errors"
+	"testing"
+
+	"backend/internal/snapshot"
+)
+
+func TestGetCachesUntilInvalidate(t *testing.T) {
+	loads := 0
+	cache := snapshot.New(func() (int, error) {
+		loads++
+		return loads, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if got, err := cache.Get(); err != nil || got != 1 {
+			t.Fatalf("Get: %d, %v", got, err)
+		}
+	}
+	cache.Invalidate()
+	if got, _ := cache.Get(); got != 2 {
+		t.Fatalf("Get after Invalidate: %d", got)
+	}
+}
+
+func TestGetDoesNotCacheErrors(t *testing.T) {
+	fail := true
+	cache := snapshot.New(func() (string, error) {
+		if fail {
+			return "", errors.New("database is locked")
+		}
+		return "rules", nil
+	})
+
+	if _, err := cache.Get(); err == nil {
+		t.Fatal("expected load error")
+	}
+	fail = false
+	if got, err := cache.Get(); err != nil || got != "rules" {
+		t.Fatalf("Get after failed load: %q, %v", got, err)
+	}
+}
+
+func TestGetDropsLoadInvalidatedConcurrently(t *testing.T) {
+	var cache *snapshot.Cache[string]
+	current, invalidate := "old", true
+	cache = snapshot.New(func() (string, error) {
+		loaded := current
+		// 读取数据后、写入缓存前数据被修改并调用 Invalidate
+		if invalidate {
+			invalidate = false
+			current = "new"
+			cache.Invalidate()
+		}
+		return loaded, nil
+	})
+
+	if got, _ := cache.Get(); got != "old" {
+		t.Fatalf("in-flight load: %q", got)
+	}
+	if got, _ := cache.Get(); got != "new" {
+		t.Fatalf("load invalidated during Get must not be cached, got %q", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package usercache 缓存认证中间件查询的用户信息及其角色和权限，用户或角色被修改、删除时自动失效
+package usercache
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type entry struct {
+	user     models.User
+	loadedAt time.Time
+}
+
+// Cache 进程内的用户缓存
+type Cache struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[uint]entry
+	// 失效计数：Get 从数据库加载期间用户被失效或缓存被清空时，丢弃加载到的可能已过期的数据
+	generations map[uint]uint64
+	epoch       uint64
+}
+
+// roleTables 影响用户权限的表，任何写入都清空缓存
+var roleTables = map[string]bool{"roles": true, "role_permissions": true, "user_roles": true}
+
+// New 创建用户缓存，并注册 GORM 回调：users 表的任何更新和删除、角色相关表的任何写入都会使缓存失效
+func New(db *gorm.DB, ttl time.Duration) *Cache {
+	c := &Cache{db: db, ttl: ttl, entries: make(map[uint]entry), generations: make(map[uint]uint64)}
+
+	db.Callback().Create().After("gorm:create").Register("usercache:invalidate", c.invalidateRolesCallback)
+	db.Callback().Update().After("gorm:update").Register("usercache:invalidate", c.invalidateCallback)
+	db.Callback().Delete().After("gorm:delete").Register("usercache:invalidate", c.invalidateCallback)
+	return c
+}
+
+// Get 返回用户的副本；缓存过期或不存在时从数据库加载，用户已删除时返回 gorm.ErrRecordNotFound
+func (c *Cache) Get(id uint) (*models.User, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	generation, epoch := c.generations[id], c.epoch
+	c.mu.Unlock()
+	if ok && now.Sub(e.loadedAt) < c.ttl {
+		user := e.user
+		return &user, nil
+	}
+
+	var user models.User
+	if err := c.db.First(&user, id).Error; err != nil {
+		c.Invalidate(id)
+		return nil, err
+	}
+	if err := models.LoadUserRoles(c.db, &user); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generations[id] == generation && c.epoch == epoch {
+		c.entries[id] = entry{user: user, loadedAt: now}
+	}
+	c.mu.Unlock()
+	return &user, nil
+}
+
+// Invalidate 使指定用户的缓存失效
+func (c *Cache) Invalidate(id uint) {
+	c.mu.Lock()
+	delete(c.entries, id)
+	c.generations[id]++
+	c.mu.Unlock()
+}
+
+// Clear 清空全部缓存
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	c.entries = make(map[uint]entry)
+	c.generations = make(map[uint]uint64)
+	c.epoch++
+	c.mu.Unlock()
+}
+
+// invalidateCallback 能确定用户ID时只清除该用户，否则（如按条件批量更新）清空缓存。
+// 在事务中时立即清除一次，使并发的加载作废，事务提交后再清除一次，丢弃提交前读到的旧数据
+func (c *Cache) invalidateCallback(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	if roleTables[stmt.Schema.Table] {
+		c.Clear()
+		database.AfterCommit(db, c.Clear)
+		return
+	}
+	if stmt.Schema.Table != "users" {
+		return
+	}
+
+	if field := stmt.Schema.PrioritizedPrimaryField; field != nil && stmt.ReflectValue.Kind() == reflect.Struct {
+		if id, zero := field.ValueOf(stmt.Context, stmt.ReflectValue); !zero {
+			if id, ok := id.(uint); ok {
+				c.Invalidate(id)
+				database.AfterCommit(db, func() { c.Invalidate(id) })
+				return
+			}
+		}
+	}
+	c.Clear()
+	database.AfterCommit(db, c.Clear)
+}
+
+// invalidateRolesCallback 新建的用户不会在缓存中，只需处理角色相关表的写入
+func (c *Cache) invalidateRolesCallback(db *gorm.DB) {
+	if db.Statement.Schema != nil && roleTables[db.Statement.Schema.Table] {
+		c.Clear()
+		database.AfterCommit(db, c.Clear)
+	}
+}
@@ -0,0 +1,118 @@
+package usercache_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/database"
+	"backend/internal/models"
+	"backend/internal/usercache"
+
+	"gorm.io/gorm"
+)
+
+func newTestCache(t *testing.T) (*gorm.DB, *usercache.Cache, *models.User) {
+	t.Helper()
+	t.Setenv("DATA_PATH", t.TempDir())
+	db, err := database.InitDB(config.LoadConfig())
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	user := models.User{Username: "bob", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return db, usercache.New(db, time.Hour), &user
+}
+
+func TestGetReturnsCachedCopy(t *testing.T) {
+	db, cache, user := newTestCache(t)
+
+	cached, err := cache.Get(user.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	cached.Username = "changed"
+
+	// 绕过回调直接修改数据库，缓存仍返回之前加载的副本
+	if err := db.Exec("UPDATE users SET username = ? WHERE id = ?", "raw", user.ID).Error; err != nil {
+		t.Fatalf("raw update: %v", err)
+	}
+	if again, _ := cache.Get(user.ID); again.Username != "bob" {
+		t.Fatalf("expected cached copy, got %q", again.Username)
+	}
+}
+
+func TestGetDropsLoadInvalidatedConcurrently(t *testing.T) {
+	db, cache, user := newTestCache(t)
+
+	// 在 Get 从数据库读取后、写入缓存前使该用户失效，模拟并发的更新
+	var invalidate atomic.Bool
+	db.Callback().Query().After("gorm:query").Register("test:invalidate", func(tx *gorm.DB) {
+		if tx.Statement.Schema != nil && tx.Statement.Schema.Table == "users" && invalidate.CompareAndSwap(true, false) {
+			cache.Invalidate(user.ID)
+		}
+	})
+
+	invalidate.Store(true)
+	if _, err := cache.Get(user.ID); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := db.Exec("UPDATE users SET username = ? WHERE id = ?", "raw", user.ID).Error; err != nil {
+		t.Fatalf("raw update: %v", err)
+	}
+	if got, _ := cache.Get(user.ID); got.Username != "raw" {
+		t.Fatalf("load invalidated during Get must not be cached, got %q", got.Username)
+	}
+}
+
+func TestInvalidateAfterCommit(t *testing.T) {
+	db, cache, user := newTestCache(t)
+	if _, err := cache.Get(user.ID); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("username", "alice").Error; err != nil {
+			return err
+		}
+		// 提交前其他连接读到旧数据并重新缓存
+		stale, err := cache.Get(user.ID)
+		if err != nil {
+			return err
+		}
+		if stale.Username != "bob" {
+			t.Errorf("uncommitted update visible outside the transaction: %q", stale.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+
+	if got, _ := cache.Get(user.ID); got.Username != "alice" {
+		t.Fatalf("cache must be invalidated after commit, got %q", got.Username)
+	}
+}
+
+func TestRoleChangesClearCache(t *testing.T) {
+	db, cache, user := newTestCache(t)
+	if cached, _ := cache.Get(user.ID); cached.IsAdmin() {
+		t.Fatal("new user should not be admin")
+	}
+
+	if _, err := models.AssignRole(db, user.ID, models.RoleAdmin, true); err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	if cached, _ := cache.Get(user.ID); !cached.IsAdmin() {
+		t.Fatal("role change must clear the cache")
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"log"
+	"time"
 	"backend/internal/config"
 	"backend/internal/database"
+	"backend/internal/keyring"
 	"backend/internal/router"
 )
 
@@ -24,8 +26,17 @@ func main() {
 	}
 	defer sqlDB.Close()
 
+	// 定期清理过期数据
+	database.StartCleanupTask(db, time.Hour)
+
+	// 加载 JWT 签名密钥
+	keys, err := keyring.New(db, cfg)
+	if err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
 	// 初始化路由
-	r := router.SetupRouter(db, cfg)
+	r := router.SetupRouter(db, cfg, keys)
 
 	// 启动服务器
 	log.Printf("Server starting on %s", cfg.ServerAddress)
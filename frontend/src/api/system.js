@@ -13,6 +13,12 @@ export const systemApi = {
     return response.data
   },
 
+  // 获取验证码
+  getCaptcha: async () => {
+    const response = await axios.get('/api/captcha')
+    return response.data
+  },
+
   // 更新系统设置
   updateSystemSettings: async (options) => {
     const response = await axios.put('/api/admin/options', { options })
@@ -2,14 +2,44 @@ import axios from 'axios'
 
 export const userApi = {
   // 登录
-  login: async (username, password) => {
-    const response = await axios.post('/api/login', { username, password })
+  login: async (username, password, rememberMe = false, captcha = {}) => {
+    const response = await axios.post('/api/login', { username, password, remember_me: rememberMe, ...captcha })
+    return response.data
+  },
+
+  // 用单点登录回调返回的一次性交换码换取令牌
+  exchangeOIDCCode: async (code) => {
+    const response = await axios.post('/api/oidc/exchange', { code })
+    return response.data
+  },
+
+  // 为当前用户关联单点登录账户，返回需要跳转的身份提供方地址
+  beginOIDCLink: async () => {
+    const response = await axios.post('/api/user/oidc/link')
+    return response.data
+  },
+
+  // 获取当前用户信息
+  getUserInfo: async () => {
+    const response = await axios.get('/api/user')
+    return response.data
+  },
+
+  // 刷新令牌，Cookie 模式下刷新令牌由浏览器携带
+  refreshToken: async (refreshToken) => {
+    const response = await axios.post('/api/token/refresh', refreshToken ? { refresh_token: refreshToken } : {})
+    return response.data
+  },
+
+  // 登出，结束服务器上的会话
+  logout: async (headers) => {
+    const response = await axios.post('/api/logout', null, { headers })
     return response.data
   },
 
   // 注册
-  register: async (username, password) => {
-    const response = await axios.post('/api/register', { username, password })
+  register: async (username, password, email, captcha = {}) => {
+    const response = await axios.post('/api/register', { username, password, email, ...captcha })
     return response.data
   },
 
@@ -46,6 +76,72 @@ export const userApi = {
     return response.data
   },
 
+  // 停用用户
+  disableUser: async (id, reason) => {
+    const response = await axios.post(`/api/admin/users/${id}/disable`, { reason })
+    return response.data
+  },
+
+  // 启用用户
+  enableUser: async (id) => {
+    const response = await axios.post(`/api/admin/users/${id}/enable`)
+    return response.data
+  },
+
+  // 以指定用户的身份登录
+  impersonate: async (id, reason) => {
+    const response = await axios.post(`/api/admin/users/${id}/impersonate`, { reason })
+    return response.data
+  },
+
+  // 获取当前用户的登录记录和安全事件
+  getLoginHistory: async (params) => {
+    const response = await axios.get('/api/user/login-history', { params })
+    return response.data
+  },
+
+  // 查询所有用户的安全事件，支持 user_id、type、ip、from、to 过滤和分页
+  listSecurityEvents: async (params) => {
+    const response = await axios.get('/api/admin/security-events', { params })
+    return response.data
+  },
+
+  // 设置用户的角色，role_ids 为空时移除全部角色
+  setUserRoles: async (id, roleIds) => {
+    const response = await axios.put(`/api/admin/users/${id}/roles`, { role_ids: roleIds })
+    return response.data
+  },
+
+  // 获取角色列表
+  listRoles: async () => {
+    const response = await axios.get('/api/admin/roles')
+    return response.data
+  },
+
+  // 创建角色
+  createRole: async (data) => {
+    const response = await axios.post('/api/admin/roles', data)
+    return response.data
+  },
+
+  // 更新角色的名称、说明和权限
+  updateRole: async (id, data) => {
+    const response = await axios.put(`/api/admin/roles/${id}`, data)
+    return response.data
+  },
+
+  // 删除角色
+  deleteRole: async (id) => {
+    const response = await axios.delete(`/api/admin/roles/${id}`)
+    return response.data
+  },
+
+  // 获取所有可分配的权限
+  listPermissions: async () => {
+    const response = await axios.get('/api/admin/permissions')
+    return response.data
+  },
+
   // 重置用户密码
   resetUserPassword: async (id) => {
     const response = await axios.post(`/api/admin/users/${id}/reset-password`)
@@ -1,9 +1,11 @@
 import { createApp } from 'vue'
 import { createPinia } from 'pinia'
+import axios from 'axios'
 import ElementPlus from 'element-plus'
 import 'element-plus/dist/index.css'
 import App from './App.vue'
 import router from './router'
+import { useUserStore } from './stores/user'
 import './assets/main.css'
 
 const app = createApp(App)
@@ -12,4 +14,31 @@ app.use(createPinia())
 app.use(router)
 app.use(ElementPlus)
 
+// Cookie 模式下携带 Cookie，并将 CSRF Cookie 回传到请求头
+axios.defaults.withCredentials = true
+axios.defaults.xsrfCookieName = 'csrf_token'
+axios.defaults.xsrfHeaderName = 'X-CSRF-Token'
+
+// 访问令牌过期时自动刷新并重试一次请求
+let refreshing = null
+axios.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const config = error.config
+    if (error.response?.status !== 401 || !config || config._retried || config.url === '/api/token/refresh') {
+      return Promise.reject(error)
+    }
+    const userStore = useUserStore()
+    refreshing = refreshing || userStore.refresh().finally(() => { refreshing = null })
+    if (!(await refreshing)) {
+      return Promise.reject(error)
+    }
+    config._retried = true
+    if (userStore.token) {
+      config.headers['Authorization'] = `Bearer ${userStore.token}`
+    }
+    return axios(config)
+  }
+)
+
 app.mount('#app') 
\ No newline at end of file
@@ -11,6 +11,12 @@ const router = createRouter({
       component: () => import('@/views/LoginView.vue'),
       meta: { requiresAuth: false }
     },
+    {
+      path: '/oidc/callback',
+      name: 'oidc-callback',
+      component: () => import('@/views/OIDCCallbackView.vue'),
+      meta: { requiresAuth: false }
+    },
     {
       path: '/register',
       name: 'register',
@@ -5,6 +5,8 @@ export const useSystemStore = defineStore('system', {
   state: () => ({
     systemName: '后台管理系统', // 默认值
     allowRegistration: true,
+    oidcEnabled: false,
+    loginCaptchaRequired: false,
     loading: false,
     sysInfoLoaded: false // 标记是否已加载过系统信息
   }),
@@ -24,6 +26,8 @@ export const useSystemStore = defineStore('system', {
         // 更新 store 中的值
         this.systemName = data.systemName || '后台管理系统'
         this.allowRegistration = data.allowRegistration
+        this.oidcEnabled = data.oidcEnabled || false
+        this.loginCaptchaRequired = data.loginCaptchaRequired || false
         this.sysInfoLoaded = true
         
         return {
@@ -1,10 +1,12 @@
 import { defineStore } from 'pinia'
 import axios from 'axios'
 import { userApi } from '../api/user'
+import { useSystemStore } from './system'
 
 export const useUserStore = defineStore('user', {
   state: () => {
     const token = localStorage.getItem('token') || ''
+    const refreshToken = localStorage.getItem('refresh_token') || ''
     const user = JSON.parse(localStorage.getItem('user')) || null
     
     // 初始化时设置 axios 默认配置
@@ -14,42 +16,141 @@ export const useUserStore = defineStore('user', {
     
     return {
       token,
+      refreshToken,
       user
     }
   },
 
   getters: {
-    isLoggedIn: (state) => !!state.token,
-    isAdmin: (state) => state.user?.is_admin || false
+    // Cookie 模式下令牌不对前端可见，以用户信息判断是否登录
+    isLoggedIn: (state) => !!(state.token || state.user),
+    isAdmin: (state) => state.user?.is_admin || false,
+    // 拥有 * 权限的角色可以执行任何操作
+    hasPermission: (state) => (permission) => {
+      const permissions = state.user?.permissions || []
+      return permissions.includes('*') || permissions.includes(permission)
+    },
+    isImpersonating: (state) => !!state.user?.impersonator
   },
 
   actions: {
-    async login(username, password) {
+    async login(username, password, rememberMe = false, captcha = {}) {
       try {
-        const data = await userApi.login(username, password)
-        this.token = data.token
+        const data = await userApi.login(username, password, rememberMe, captcha)
+        if (data.token) {
+          this.setTokens(data.token, data.refresh_token)
+        }
         this.user = data.user
-        localStorage.setItem('token', this.token)
         localStorage.setItem('user', JSON.stringify(this.user))
-        axios.defaults.headers.common['Authorization'] = `Bearer ${this.token}`
         return true
       } catch (error) {
+        // 连续失败后服务器要求验证码
+        if (error.response?.data?.captcha_required) {
+          useSystemStore().loginCaptchaRequired = true
+        }
         throw error.response?.data?.error || '登录失败'
       }
     },
 
-    async register(username, password) {
+    // 单点登录回调后用交换码换取令牌，Cookie 模式下没有令牌
+    async loginWithOIDCCode(code) {
+      try {
+        const data = await userApi.exchangeOIDCCode(code)
+        if (data.token) {
+          this.setTokens(data.token, data.refresh_token)
+        }
+        this.user = data.user
+        localStorage.setItem('user', JSON.stringify(this.user))
+      } catch (error) {
+        throw error.response?.data?.error || '登录失败'
+      }
+    },
+
+    setTokens(token, refreshToken) {
+      this.token = token
+      this.refreshToken = refreshToken
+      localStorage.setItem('token', token)
+      localStorage.setItem('refresh_token', refreshToken)
+      axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
+    },
+
+    // 模拟登录：保存管理员自己的登录状态，切换为目标用户的令牌
+    async startImpersonation(id, reason) {
+      try {
+        const data = await userApi.impersonate(id, reason)
+        const backup = { token: this.token, refreshToken: this.refreshToken, user: this.user }
+        localStorage.setItem('impersonation_backup', JSON.stringify(backup))
+        this.setTokens(data.token, '')
+        this.user = await userApi.getUserInfo()
+        localStorage.setItem('user', JSON.stringify(this.user))
+      } catch (error) {
+        throw error.response?.data?.error || '模拟登录失败'
+      }
+    },
+
+    // 结束模拟登录，恢复管理员自己的登录状态
+    stopImpersonation() {
+      const backup = JSON.parse(localStorage.getItem('impersonation_backup'))
+      localStorage.removeItem('impersonation_backup')
+      if (this.token) {
+        userApi.logout({ Authorization: `Bearer ${this.token}` }).catch(() => {})
+      }
+      if (backup?.token) {
+        this.setTokens(backup.token, backup.refreshToken)
+      } else {
+        this.token = ''
+        this.refreshToken = ''
+        localStorage.removeItem('token')
+        localStorage.removeItem('refresh_token')
+        delete axios.defaults.headers.common['Authorization']
+      }
+      this.user = backup?.user || null
+      localStorage.setItem('user', JSON.stringify(this.user))
+    },
+
+    // 访问令牌过期后使用刷新令牌换取新令牌，模拟登录的令牌不能刷新
+    async refresh() {
+      if ((!this.refreshToken && !this.user) || this.isImpersonating) {
+        return false
+      }
       try {
-        return await userApi.register(username, password)
+        const data = await userApi.refreshToken(this.refreshToken)
+        if (data.token) {
+          this.setTokens(data.token, data.refresh_token)
+        }
+        return true
+      } catch (error) {
+        this.logout()
+        return false
+      }
+    },
+
+    async register(username, password, email, captcha = {}) {
+      try {
+        return await userApi.register(username, password, email, captcha)
       } catch (error) {
         throw error.response?.data?.error || '注册失败'
       }
     },
 
     logout() {
+      // 通知服务器结束会话，Cookie 模式下同时由服务器清除 Cookie
+      if (this.token || this.user) {
+        const headers = this.token ? { Authorization: `Bearer ${this.token}` } : {}
+        userApi.logout(headers).catch(() => {})
+      }
+      // 模拟登录期间退出时同时结束管理员自己的会话
+      const backup = JSON.parse(localStorage.getItem('impersonation_backup'))
+      if (backup?.token || backup?.user) {
+        const headers = backup.token ? { Authorization: `Bearer ${backup.token}` } : {}
+        userApi.logout(headers).catch(() => {})
+      }
+      localStorage.removeItem('impersonation_backup')
       this.token = ''
+      this.refreshToken = ''
       this.user = null
       localStorage.removeItem('token')
+      localStorage.removeItem('refresh_token')
       localStorage.removeItem('user')
       delete axios.defaults.headers.common['Authorization']
     },
@@ -87,6 +188,22 @@ export const useUserStore = defineStore('user', {
       }
     },
 
+    async setUserRoles(id, roleIds) {
+      try {
+        return await userApi.setUserRoles(id, roleIds)
+      } catch (error) {
+        throw error.response?.data?.error || '设置角色失败'
+      }
+    },
+
+    async fetchRoles() {
+      try {
+        return await userApi.listRoles()
+      } catch (error) {
+        throw error.response?.data?.error || '获取角色列表失败'
+      }
+    },
+
     async deleteUser(id) {
       try {
         return await userApi.deleteUser(id)
@@ -95,6 +212,22 @@ export const useUserStore = defineStore('user', {
       }
     },
 
+    async disableUser(id, reason) {
+      try {
+        return await userApi.disableUser(id, reason)
+      } catch (error) {
+        throw error.response?.data?.error || '停用用户失败'
+      }
+    },
+
+    async enableUser(id) {
+      try {
+        return await userApi.enableUser(id)
+      } catch (error) {
+        throw error.response?.data?.error || '启用用户失败'
+      }
+    },
+
     async resetUserPassword(id) {
       try {
         return await userApi.resetUserPassword(id)